@@ -94,6 +94,17 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("%w: cannot initialize solana client", err)
 		}
 		defer client.Close()
+		client.Mempool = solanago.NewMempool(cfg.MempoolSize)
+
+		// the rebroadcaster also keeps the mempool served by /mempool current
+		rebroadcaster := solanago.NewRebroadcaster(
+			client,
+			cfg.RebroadcastInterval,
+			cfg.RebroadcastRefreshInterval,
+		)
+		g.Go(func() error {
+			return rebroadcaster.Start(ctx)
+		})
 	}
 
 	router := services.NewBlockchainRouter(cfg, client, asserter)
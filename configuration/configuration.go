@@ -17,9 +17,11 @@ package configuration
 import (
 	"errors"
 	"fmt"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
 	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
 	"os"
 	"strconv"
+	"time"
 
 	rpc "github.com/blocto/solana-go-sdk/rpc"
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -67,6 +69,28 @@ const (
 	// when GethEnv is not populated.
 	DefaultGethURL = "http://localhost:8545"
 
+	// RebroadcastIntervalEnv is an optional environment variable
+	// with the duration between re-sends of submitted transactions
+	// that have not landed yet. 0 disables rebroadcasting.
+	RebroadcastIntervalEnv = "REBROADCAST_INTERVAL"
+
+	// RebroadcastRefreshIntervalEnv is an optional environment
+	// variable with the duration between checks that the submitted
+	// transactions landed or expired.
+	RebroadcastRefreshIntervalEnv = "REBROADCAST_REFRESH_INTERVAL"
+
+	// MempoolSizeEnv is an optional environment variable with the
+	// most submitted transactions tracked at once.
+	MempoolSizeEnv = "MEMPOOL_SIZE"
+
+	// DefaultRebroadcastInterval is used when RebroadcastIntervalEnv
+	// is not populated.
+	DefaultRebroadcastInterval = 2 * time.Second
+
+	// DefaultRebroadcastRefreshInterval is used when
+	// RebroadcastRefreshIntervalEnv is not populated.
+	DefaultRebroadcastRefreshInterval = 10 * time.Second
+
 	// MiddlewareVersion is the version of rosetta-solanago.
 	MiddlewareVersion = "0.0.4"
 )
@@ -80,6 +104,10 @@ type Configuration struct {
 	RemoteGeth             bool
 	Port                   int
 	GethArguments          string
+
+	RebroadcastInterval        time.Duration
+	RebroadcastRefreshInterval time.Duration
+	MempoolSize                int
 }
 
 // LoadConfiguration attempts to create a new Configuration
@@ -147,5 +175,40 @@ func LoadConfiguration() (*Configuration, error) {
 	}
 	config.Port = port
 
+	config.RebroadcastInterval, err = durationEnv(RebroadcastIntervalEnv, DefaultRebroadcastInterval)
+	if err != nil {
+		return nil, err
+	}
+	config.RebroadcastRefreshInterval, err = durationEnv(RebroadcastRefreshIntervalEnv, DefaultRebroadcastRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+	if config.RebroadcastRefreshInterval <= 0 {
+		return nil, fmt.Errorf("%s must be positive", RebroadcastRefreshIntervalEnv)
+	}
+
+	config.MempoolSize = solanago.DefaultMempoolSize
+	if sizeValue := os.Getenv(MempoolSizeEnv); len(sizeValue) > 0 {
+		size, err := strconv.Atoi(sizeValue)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("unable to parse %s %s", MempoolSizeEnv, sizeValue)
+		}
+		config.MempoolSize = size
+	}
+
 	return config, nil
 }
+
+// durationEnv parses the duration in the environment variable env,
+// returning def when it is not populated.
+func durationEnv(env string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(env)
+	if len(value) == 0 {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("unable to parse %s %s", env, value)
+	}
+	return d, nil
+}
@@ -68,5 +68,20 @@ func (s *AccountAPIService) AccountCoins(
 	ctx context.Context,
 	request *types.AccountCoinsRequest,
 ) (*types.AccountCoinsResponse, *types.Error) {
-	return nil, wrapErr(ErrUnimplemented, nil)
+	log.Printf("START /account/coins")
+	if s.config.Mode != configuration.Online {
+		return nil, ErrUnavailableOffline
+	}
+	coinsResponse, err := s.client.Coins(
+		ctx,
+		request.AccountIdentifier,
+		request.IncludeMempool,
+		request.Currencies,
+	)
+	if err != nil {
+		return nil, wrapErr(ErrGeth, err)
+	}
+
+	log.Printf("END /account/coins")
+	return coinsResponse, nil
 }
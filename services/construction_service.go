@@ -15,8 +15,14 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/blocto/solana-go-sdk/program/associated_token_account"
+	"github.com/blocto/solana-go-sdk/program/stakeprog"
 	"github.com/blocto/solana-go-sdk/program/system"
 	"github.com/imerkle/rosetta-solana-go/solana/parse"
 	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
@@ -24,12 +30,13 @@ import (
 	"strconv"
 	"strings"
 
+	ss "github.com/blocto/solana-go-sdk/client"
 	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
 	solPTypes "github.com/blocto/solana-go-sdk/types"
 	"github.com/imerkle/rosetta-solana-go/configuration"
 	solanago "github.com/imerkle/rosetta-solana-go/solana"
 	"github.com/imerkle/rosetta-solana-go/solana/operations"
-	"github.com/mitchellh/copystructure"
 	"github.com/mr-tron/base58"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -47,7 +54,11 @@ func NewConstructionAPIService(
 	cfg *configuration.Configuration,
 	client *solanago.Client,
 ) *ConstructionAPIService {
-	var directClient = solanago.NewDirectClient(cfg.GethURL)
+	// offline the service has no node to talk to
+	var directClient *solanago.DirectClient
+	if cfg.Mode == configuration.Online {
+		directClient = solanago.NewDirectClient(cfg.GethURL)
+	}
 	return &ConstructionAPIService{
 		config:       cfg,
 		client:       client,
@@ -60,14 +71,108 @@ func (s *ConstructionAPIService) ConstructionDerive(
 	ctx context.Context,
 	request *types.ConstructionDeriveRequest,
 ) (*types.ConstructionDeriveResponse, *types.Error) {
-	addr := base58.Encode(request.PublicKey.Bytes)
+	if request.PublicKey == nil {
+		return nil, wrapErr(ErrUnableToDecompressPubkey, fmt.Errorf("public key is required"))
+	}
+	if request.PublicKey.CurveType != types.Edwards25519 {
+		return nil, wrapErr(ErrUnableToDecompressPubkey, fmt.Errorf("curve type %s is not supported, keys are %s", request.PublicKey.CurveType, types.Edwards25519))
+	}
+	if len(request.PublicKey.Bytes) != ed25519.PublicKeySize {
+		return nil, wrapErr(ErrUnableToDecompressPubkey, fmt.Errorf("public key is %d bytes, not %d", len(request.PublicKey.Bytes), ed25519.PublicKeySize))
+	}
+	key := common.PublicKeyFromBytes(request.PublicKey.Bytes)
+	if !common.IsOnCurve(key) {
+		return nil, wrapErr(ErrUnableToDecompressPubkey, fmt.Errorf("public key is not a point of the curve"))
+	}
+
+	var metadata DeriveMetadata
+	if err := unmarshalJSONMap(request.Metadata, &metadata); err != nil {
+		return nil, wrapErr(ErrDeriveMetadataInvalid, err)
+	}
+	account, err := deriveAccount(key, metadata)
+	if err != nil {
+		return nil, wrapErr(ErrDeriveMetadataInvalid, err)
+	}
 	return &types.ConstructionDeriveResponse{
-		AccountIdentifier: &types.AccountIdentifier{
-			Address: addr,
-		},
+		AccountIdentifier: account,
 	}, nil
 }
 
+// deriveAccount returns the account of key, or the associated token
+// account of key or the program derived address that metadata asks for.
+// An associated token account is returned as sub-account of key.
+func deriveAccount(key common.PublicKey, metadata DeriveMetadata) (*types.AccountIdentifier, error) {
+	switch {
+	case metadata.Mint != "" && metadata.ProgramID != "":
+		return nil, fmt.Errorf("mint and program_id cannot be derived together")
+	case metadata.Mint != "":
+		if !operations.IsAddress(metadata.Mint) {
+			return nil, fmt.Errorf("mint %s is not a valid public key", metadata.Mint)
+		}
+		account, _, err := common.FindAssociatedTokenAddress(key, p(metadata.Mint))
+		if err != nil {
+			return nil, err
+		}
+		return &types.AccountIdentifier{
+			Address: key.ToBase58(),
+			SubAccount: &types.SubAccountIdentifier{
+				Address:  account.ToBase58(),
+				Metadata: map[string]interface{}{"mint": metadata.Mint},
+			},
+		}, nil
+	case metadata.ProgramID != "":
+		if !operations.IsAddress(metadata.ProgramID) {
+			return nil, fmt.Errorf("program_id %s is not a valid public key", metadata.ProgramID)
+		}
+		seeds, err := deriveSeeds(metadata.Seeds)
+		if err != nil {
+			return nil, err
+		}
+		address, bump, err := common.FindProgramAddress(seeds, p(metadata.ProgramID))
+		if err != nil {
+			return nil, err
+		}
+		return &types.AccountIdentifier{
+			Address:  address.ToBase58(),
+			Metadata: map[string]interface{}{"program_id": metadata.ProgramID, "bump": bump},
+		}, nil
+	case len(metadata.Seeds) > 0:
+		return nil, fmt.Errorf("seeds need a program_id")
+	}
+	return &types.AccountIdentifier{Address: key.ToBase58()}, nil
+}
+
+// deriveSeeds decodes the seeds of a program derived address. The bump
+// seed found for them is one more seed.
+func deriveSeeds(seeds []DeriveSeed) ([][]byte, error) {
+	if len(seeds) >= common.MaxSeed {
+		return nil, fmt.Errorf("%d seeds are more than the %d a program derived address has besides its bump", len(seeds), common.MaxSeed-1)
+	}
+	var decoded [][]byte
+	for i, seed := range seeds {
+		var b []byte
+		var err error
+		switch {
+		case seed.UTF8 != "" && seed.Hex == "" && seed.Base58 == "":
+			b = []byte(seed.UTF8)
+		case seed.Hex != "" && seed.UTF8 == "" && seed.Base58 == "":
+			b, err = hex.DecodeString(seed.Hex)
+		case seed.Base58 != "" && seed.UTF8 == "" && seed.Hex == "":
+			b, err = base58.Decode(seed.Base58)
+		default:
+			return nil, fmt.Errorf("seed %d needs exactly one of utf8, hex or base58", i)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("seed %d: %w", i, err)
+		}
+		if len(b) > common.MaxSeedLength {
+			return nil, fmt.Errorf("seed %d is %d bytes, more than %d", i, len(b), common.MaxSeedLength)
+		}
+		decoded = append(decoded, b)
+	}
+	return decoded, nil
+}
+
 // ConstructionPreprocess implements the /construction/preprocess
 // endpoint.
 func (s *ConstructionAPIService) ConstructionPreprocess(
@@ -79,10 +184,21 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 
 	withNonce, hasNonce := solanago.GetWithNonce(request.Metadata)
 	log.Printf("withNonce=%+v\n", withNonce)
-	if hasNonce {
+	if hasNonce && s.config.Mode != configuration.Online {
+		// the nonce account cannot be fetched, so its authority has to be given
+		if withNonce.Authority == "" {
+			return nil, wrapErr(ErrUnavailableOffline, fmt.Errorf("with_nonce needs the authority of nonce account %s offline", withNonce.Account))
+		}
+		if !operations.IsAddress(withNonce.Account) || !operations.IsAddress(withNonce.Authority) {
+			return nil, wrapErr(ErrNonceAccountInvalid, fmt.Errorf("nonce account %s or its authority %s is not a valid public key", withNonce.Account, withNonce.Authority))
+		}
+	} else if hasNonce {
 		log.Printf("inside hasNonce=true")
-		acc, _ := s.directClient.GetAccountInfoParsed(ctx, withNonce.Account)
-		withNonce.Authority = acc.Data.Parsed.Info.Authority
+		nonceAccount, err := s.directClient.GetNonceAccount(ctx, withNonce.Account)
+		if err != nil {
+			return nil, wrapNonceErr(err)
+		}
+		withNonce.Authority = nonceAccount.Authority
 	} else {
 		log.Printf("inside hasNonce=false")
 	}
@@ -92,37 +208,40 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 
 	log.Printf("request.Operations=%+v\n", request.Operations)
 
-	var matchedOperationHashMap = make(map[int64]bool)
+	var constructionMetaData = ConstructionMetadata{
+		PriorityFee: priorityFee,
+		WithNonce:   withNonce,
+		FeePayer:    solanago.GetFeePayer(request.Metadata),
+	}
 
-	var SplSystemAccMap = make(map[int64]stypes.SplAccounts)
-	for _, op := range request.Operations {
-		LogOperation(op)
+	feePayer, built, buildErr := buildOperations(request.Operations, constructionMetaData)
+	if buildErr != nil {
+		return nil, buildErr
+	}
 
-		var cont bool
-		var matched *types.Operation
-		cont, matched = FindMatch(request.Operations, op, matchedOperationHashMap)
-		if cont {
-			continue
-		}
-		if matched != nil && op.Type == stypes.SplToken__TransferWithSystem {
-			SplSystemAccMap[op.OperationIdentifier.Index] = stypes.SplAccounts{
-				Source:      op.Account.Address,
-				Destination: matched.Account.Address,
-				Mint:        op.Amount.Currency.Symbol,
+	var SplSystemAccMap = make(map[int64]stypes.SplAccounts)
+	for _, b := range built {
+		if b.op.Type == stypes.SplToken__TransferWithSystem {
+			destination, _ := b.op.Metadata["destination"].(string)
+			SplSystemAccMap[b.op.OperationIdentifier.Index] = stypes.SplAccounts{
+				Source:      b.op.Account.Address,
+				Destination: destination,
+				Mint:        b.op.Amount.Currency.Symbol,
 			}
-			matchedOperationHashMap[op.OperationIdentifier.Index] = true
 		}
 	}
 
-	var constructionMetaData = ConstructionMetadata{
-		PriorityFee: priorityFee,
-		WithNonce:   withNonce,
+	nonceOptions, nonceErr := NonceOptionsFromOperations(request.Operations)
+	if nonceErr != nil {
+		return nil, nonceErr
 	}
 
-	_, instructions, _ := ToInstructions(request.Operations, constructionMetaData)
+	if err := checkTransactionSize(feePayer, withNonce, built); err != nil {
+		return nil, err
+	}
 
-	instructions = AdvanceNonce(withNonce, instructions)
-	signers := GetUniqueSigners(instructions)
+	instructions := AdvanceNonce(withNonce, joinInstructions(built))
+	signers := transactionSigners(feePayer, instructions)
 
 	var feeCalculation = stypes.FeeCalculation{
 		NumberOfInstructions: strconv.Itoa(len(instructions)),
@@ -130,14 +249,21 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 	}
 	log.Printf("instructions.len=%+v\n", len(instructions))
 
+	options := map[string]interface{}{
+		stypes.WithNonceKey:       withNonce,
+		stypes.FeeCalculationKey:  feeCalculation,
+		stypes.PriorityFeeKey:     priorityFee,
+		stypes.SplSystemAccMapKey: SplSystemAccMap,
+		stypes.NonceOptionsKey:    nonceOptions,
+		stypes.StakeRentExemptKey: StakeRentExemptFromOperations(request.Operations),
+	}
+	if constructionMetaData.FeePayer != "" {
+		options[stypes.FeePayerKey] = constructionMetaData.FeePayer
+	}
+
 	log.Printf("END /construction/preprocess")
 	return &types.ConstructionPreprocessResponse{
-		Options: map[string]interface{}{
-			stypes.WithNonceKey:       withNonce,
-			stypes.FeeCalculationKey:  feeCalculation,
-			stypes.PriorityFeeKey:     priorityFee,
-			stypes.SplSystemAccMapKey: SplSystemAccMap,
-		},
+		Options: options,
 	}, nil
 }
 
@@ -187,13 +313,17 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 
 	if hasNonce {
 		log.Printf("inside hasNonce=true")
-		acc, _ := s.directClient.GetAccountInfoParsed(ctx, withNonce.Account)
-		//s.client.Rpc.GetAccountInfo(ctx, withNonce.Account)
-		withNonce.Authority = acc.Data.Parsed.Info.Authority
-		hash = acc.Data.Parsed.Info.BlockHash
-		var ssFeeCalculator = acc.Data.Parsed.Info.FeeCalculator
-		feeCalculator = stypes.FeeCalculator{LamportsPerSignature: solanago.ValueToBaseAmount(ssFeeCalculator.LamportsPerSignature)}
-		log.Printf("acc=%+v\n", acc)
+		nonceAccount, err := s.directClient.GetNonceAccount(ctx, withNonce.Account)
+		if err != nil {
+			return nil, wrapNonceErr(err)
+		}
+		if withNonce.Authority != "" && withNonce.Authority != nonceAccount.Authority {
+			return nil, wrapErr(ErrNonceAccountInvalid, fmt.Errorf("%s is not the authority of nonce account %s", withNonce.Authority, withNonce.Account))
+		}
+		withNonce.Authority = nonceAccount.Authority
+		hash = nonceAccount.BlockHash
+		feeCalculator = stypes.FeeCalculator{LamportsPerSignature: nonceAccount.LamportsPerSignature}
+		log.Printf("nonceAccount=%+v\n", nonceAccount)
 	} else {
 		log.Printf("inside hasNonce=false")
 		status, _, _, _, err := s.client.Status(ctx)
@@ -208,6 +338,20 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 		log.Printf("blockHash=%s\n", hash)
 	}
 
+	nonceMeta, nonceErr := s.getNonceMetadata(ctx, solanago.GetNonceOptions(request.Options))
+	if nonceErr != nil {
+		return nil, nonceErr
+	}
+
+	var stakeRentExempt uint64
+	if needed, _ := request.Options[stypes.StakeRentExemptKey].(bool); needed {
+		rent, err := s.client.Rpc.GetMinimumBalanceForRentExemption(ctx, stakeprog.AccountSize)
+		if err != nil {
+			return nil, wrapErr(ErrGeth, err)
+		}
+		stakeRentExempt = rent
+	}
+
 	var SplTokenAccMap = make(map[string]stypes.SplAccounts)
 
 	if w, ok := request.Options[stypes.SplSystemAccMapKey]; ok {
@@ -236,6 +380,9 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 		SplTokenAccMapKey: SplTokenAccMap,
 		WithNonce:         withNonce,
 		FeeCalculation:    feeCalculation,
+		Nonce:             nonceMeta,
+		StakeRentExempt:   stakeRentExempt,
+		FeePayer:          solanago.GetFeePayer(request.Options),
 	})
 
 	log.Printf("meta=%+v\n", meta)
@@ -270,12 +417,19 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 	log.Printf("meta=%+v\n", meta)
 	ops := request.Operations
 
-	feePayer, instructions, toInstructionsErr := ToInstructions(ops, meta)
-	if toInstructionsErr != nil {
-		return nil, toInstructionsErr
+	feePayer, built, buildErr := buildOperations(ops, meta)
+	if buildErr != nil {
+		return nil, buildErr
 	}
+	if err := checkTransactionSize(feePayer, meta.WithNonce, built); err != nil {
+		return nil, err
+	}
+	instructions := joinInstructions(built)
 	// this list is without the nonce-advance and so we can use the first signer as the default if needed
 	signers := GetUniqueSigners(instructions)
+	if len(signers) == 0 {
+		return nil, wrapErr(ErrUnclearIntent, fmt.Errorf("operations do not require any signer"))
+	}
 
 	if feePayer == (common.PublicKey{}) {
 		feePayer = common.PublicKeyFromString(signers[0])
@@ -284,8 +438,17 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 	blockHash := meta.BlockHash
 	var message solPTypes.Message
 
-	instructions = AdvanceNonce(meta.WithNonce, instructions)
 	_, hasNonce := solanago.GetWithNonce(request.Metadata)
+	if hasNonce && meta.WithNonce.Authority == "" {
+		return nil, wrapErr(ErrNonceAccountInvalid, fmt.Errorf("authority of nonce account %s is unknown", meta.WithNonce.Account))
+	}
+	instructions = AdvanceNonce(meta.WithNonce, instructions)
+	signers = transactionSigners(feePayer, instructions)
+	for account, nonceAccount := range meta.Nonce.Accounts {
+		if !solanago.Contains(signers, nonceAccount.Authority) {
+			return nil, wrapErr(ErrNonceAccountInvalid, fmt.Errorf("authority %s of nonce account %s is not a signer", nonceAccount.Authority, account))
+		}
+	}
 	if hasNonce {
 		message = solPTypes.NewMessage(solPTypes.NewMessageParam{FeePayer: feePayer, Instructions: instructions, RecentBlockhash: ""})
 	} else {
@@ -334,7 +497,7 @@ func AdvanceNonce(withNonce stypes.WithNonce, instructions []solPTypes.Instructi
 	// If a nonce is specified we have to advance it
 	if (withNonce != stypes.WithNonce{}) {
 		log.Printf("ToInstructions withNonce=%+v\n", withNonce)
-		ins := system.AdvanceNonceAccount(system.AdvanceNonceAccountParam{p(withNonce.Account), p(withNonce.Authority)})
+		ins := system.AdvanceNonceAccount(system.AdvanceNonceAccountParam{Nonce: p(withNonce.Account), Auth: p(withNonce.Authority)})
 		instructions = append([]solPTypes.Instruction{ins}, instructions...)
 	}
 	return instructions
@@ -354,16 +517,36 @@ func (s *ConstructionAPIService) ConstructionCombine(
 	}
 	var pubKeys []common.PublicKey
 	for _, s := range request.Signatures {
+		if s.PublicKey == nil {
+			return nil, signatureErr(fmt.Errorf("signature has no public key"), nil)
+		}
+		if s.SignatureType != types.Ed25519 {
+			return nil, signatureErr(fmt.Errorf("signature type %s is not %s", s.SignatureType, types.Ed25519), map[string]interface{}{
+				"public_key":     base58.Encode(s.PublicKey.Bytes),
+				"signature_type": s.SignatureType,
+			})
+		}
 		pubKeys = append(pubKeys, common.PublicKeyFromBytes(s.PublicKey.Bytes))
 	}
 	positions, errr := GetSigningKeypairPositions(tx.Message, pubKeys)
 	if errr != nil {
+		return nil, errr
+	}
+	message, err := tx.Message.Serialize()
+	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 	for i, p := range positions {
-		log.Printf("p=%d, i=%d", p, i)
-		tx.Signatures[p] = request.Signatures[i].Bytes
-		log.Printf("tx.Signatures[p]=%s", base58.Encode(tx.Signatures[p]))
+		sig := request.Signatures[i].Bytes
+		if len(sig) != ed25519.SignatureSize || !ed25519.Verify(pubKeys[i].Bytes(), message, sig) {
+			return nil, signatureErr(fmt.Errorf("signature of %s does not match the transaction", pubKeys[i].ToBase58()), map[string]interface{}{
+				"public_key": pubKeys[i].ToBase58(),
+			})
+		}
+		tx.Signatures[p] = sig
+	}
+	if sigErr := verifySignatures(tx, message, true); sigErr != nil {
+		return nil, sigErr
 	}
 	signedTx, err := tx.Serialize()
 	if err != nil {
@@ -387,23 +570,36 @@ func (s *ConstructionAPIService) ConstructionParse(
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
+	if len(tx.Message.Accounts) < int(tx.Message.Header.NumRequireSignatures) {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, fmt.Errorf("invalid positions"))
+	}
+	message, err := tx.Message.Serialize()
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+	if sigErr := verifySignatures(tx, message, request.Signed); sigErr != nil {
+		return nil, sigErr
+	}
+
 	var signers []*types.AccountIdentifier
-	sgns := GetUniqueSigners(tx.Message.DecompileInstructions())
-	for _, v := range sgns {
-		signers = append(signers, &types.AccountIdentifier{
-			Address: v,
-		})
+	if request.Signed {
+		for _, v := range tx.Message.Accounts[:tx.Message.Header.NumRequireSignatures] {
+			signers = append(signers, &types.AccountIdentifier{
+				Address: v.ToBase58(),
+			})
+		}
 	}
 	parsedTx, err := parse.ToParsedTransaction(tx)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
-	operations := solanago.GetRosOperationsFromTx(parsedTx, "")
+	operations, metadata := RecoverIntent(tx, parsedTx)
 
 	resp := &types.ConstructionParseResponse{
 		Operations:               operations,
 		AccountIdentifierSigners: signers,
+		Metadata:                 metadata,
 	}
 	return resp, nil
 }
@@ -414,6 +610,9 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 	request *types.ConstructionSubmitRequest,
 ) (*types.TransactionIdentifierResponse, *types.Error) {
 	log.Printf("START /construction/submit")
+	if s.config.Mode != configuration.Online {
+		return nil, ErrUnavailableOffline
+	}
 
 	version, _ := s.client.Rpc.GetVersion(ctx)
 	log.Printf("s.config.GethURL=%s\n", s.config.GethURL)
@@ -423,9 +622,6 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 	log.Printf("request.SignedTransaction\n%s\n", request.SignedTransaction)
 
 	log.Printf("before Rpc.SendTransaction")
-	if s.config.Mode != configuration.Online {
-		return nil, ErrUnavailableOffline
-	}
 	decode, err2 := base58.Decode(request.SignedTransaction)
 	if err2 != nil {
 		log.Printf("err=%s", err2)
@@ -437,20 +633,69 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 		return nil, wrapErr(ErrBroadcastFailed, err)
 	}
 
-	hash, err := s.client.Rpc.SendTransaction(ctx, transaction)
+	opts, err := solanago.GetSubmitOptions(submitMetadata(ctx))
 	if err != nil {
-		return nil, wrapErr(ErrBroadcastFailed, err)
+		return nil, wrapErr(ErrSubmitOptionsInvalid, err)
+	}
+	sendConfig := ss.SendTransactionConfig{
+		SkipPreflight: opts.SkipPreflight,
+		MaxRetries:    opts.MaxRetries,
+	}
+	if len(opts.PreflightCommitment) > 0 {
+		sendConfig.PreflightCommitment, err = solanago.ParseCommitment(opts.PreflightCommitment)
+		if err != nil {
+			return nil, wrapErr(ErrSubmitOptionsInvalid, err)
+		}
+	}
+	var waitFor rpc.Commitment
+	var lastValidBlockHeight uint64
+	if len(opts.WaitFor) > 0 {
+		waitFor, err = solanago.ParseCommitment(opts.WaitFor)
+		if err != nil {
+			return nil, wrapErr(ErrSubmitOptionsInvalid, err)
+		}
+		// the transaction's blockhash is no newer than the latest one,
+		// so it expires no later than the latest lastValidBlockHeight
+		latest, err := s.client.Rpc.GetLatestBlockhash(ctx)
+		if err != nil {
+			return nil, wrapErr(ErrGeth, err)
+		}
+		lastValidBlockHeight = latest.LatestValidBlockHeight
+	}
+
+	hash, err := s.client.Rpc.SendTransactionWithConfig(ctx, transaction, sendConfig)
+	if err != nil {
+		return nil, wrapSubmitErr(err, transaction)
 	}
 	log.Printf("after Rpc.SendTransaction")
 	log.Printf("hash=%s\n", hash)
+	if !s.client.AddToMempool(ctx, hash, transaction) {
+		log.Printf("mempool full, %s is not rebroadcast", hash)
+	}
 
 	txIdentifier := &types.TransactionIdentifier{
 		Hash: hash,
 	}
 
+	var metadata map[string]interface{}
+	if len(waitFor) > 0 {
+		confirmation, err := s.client.WaitForConfirmation(ctx, hash, transaction, waitFor, lastValidBlockHeight)
+		if err != nil {
+			return nil, wrapErr(ErrGeth, err)
+		}
+		metadata = map[string]interface{}{
+			"status": confirmation.Status,
+			"slot":   confirmation.Slot,
+		}
+		if confirmation.Err != nil {
+			metadata["error"] = confirmation.Err
+		}
+	}
+
 	log.Printf("END /construction/submit")
 	return &types.TransactionIdentifierResponse{
 		TransactionIdentifier: txIdentifier,
+		Metadata:              metadata,
 	}, nil
 }
 
@@ -468,40 +713,6 @@ func LogOperation(op *types.Operation) {
 	}
 }
 
-func FindMatch(ops []*types.Operation, op *types.Operation, matchedOperationHashMap map[int64]bool) (bool, *types.Operation) {
-	if _, ok := matchedOperationHashMap[op.OperationIdentifier.Index]; ok {
-		return true, nil
-	}
-	var matched *types.Operation = nil
-	for _, v := range ops {
-		if op.OperationIdentifier.Index == v.OperationIdentifier.Index {
-			continue
-		}
-		if _, ok := matchedOperationHashMap[v.OperationIdentifier.Index]; ok {
-			continue
-		}
-		if v.Type != op.Type {
-			continue
-		}
-		if v.Amount != nil {
-			if v.Amount.Currency.Symbol != op.Amount.Currency.Symbol {
-				continue
-			}
-			if solanago.ValueToBaseAmount(v.Amount.Value) != solanago.ValueToBaseAmount(op.Amount.Value) {
-				continue
-			} else {
-				opisNegative := strings.Contains(op.Amount.Value, "-")
-				visNegative := strings.Contains(v.Amount.Value, "-")
-				if (opisNegative && visNegative) || (!opisNegative && !visNegative) {
-					continue
-				}
-			}
-		}
-		return false, v
-	}
-	return false, matched
-}
-
 func NewMessageWithNonce(feePayer common.PublicKey, instructions []solPTypes.Instruction, nonceAccountPubkey common.PublicKey, nonceAuthorityPubkey common.PublicKey) solPTypes.Message {
 	//ins := system.AdvanceNonceAccount(system.AdvanceNonceAccountParam{nonceAccountPubkey, nonceAuthorityPubkey})
 	//instructions = append([]solPTypes.Instruction{ins}, instructions...)
@@ -517,12 +728,59 @@ func GetSigningKeypairPositions(message solPTypes.Message, pubKeys []common.Publ
 	var positions []uint
 	for _, p := range pubKeys {
 		index := indexOf(p, signedKeys)
-		if index > -1 {
-			positions = append(positions, uint(index))
+		if index < 0 {
+			return nil, signatureErr(fmt.Errorf("%s is not a signer of the transaction", p.ToBase58()), map[string]interface{}{
+				"public_key": p.ToBase58(),
+			})
 		}
+		positions = append(positions, uint(index))
 	}
 	return positions, nil
 }
+
+// verifySignatures checks the non-empty signatures of tx against
+// message, its serialized message, and when signed that every required
+// signer signed.
+func verifySignatures(tx solPTypes.Transaction, message []byte, signed bool) *types.Error {
+	var missing []string
+	for i := 0; i < int(tx.Message.Header.NumRequireSignatures) && i < len(tx.Message.Accounts); i++ {
+		signer := tx.Message.Accounts[i]
+		if i >= len(tx.Signatures) || isEmptySignature(tx.Signatures[i]) {
+			missing = append(missing, signer.ToBase58())
+			continue
+		}
+		if len(tx.Signatures[i]) != ed25519.SignatureSize || !ed25519.Verify(signer.Bytes(), message, tx.Signatures[i]) {
+			return signatureErr(fmt.Errorf("signature of %s does not match the transaction", signer.ToBase58()), map[string]interface{}{
+				"public_key": signer.ToBase58(),
+			})
+		}
+	}
+	if signed && len(missing) > 0 {
+		return signatureErr(fmt.Errorf("missing signatures of %s", strings.Join(missing, ", ")), map[string]interface{}{
+			"missing_signers": missing,
+		})
+	}
+	return nil
+}
+
+func isEmptySignature(sig solPTypes.Signature) bool {
+	for _, b := range sig {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// signatureErr returns ErrSignatureInvalid for err with details.
+func signatureErr(err error, details map[string]interface{}) *types.Error {
+	newErr := wrapErr(ErrSignatureInvalid, err)
+	for k, v := range details {
+		newErr.Details[k] = v
+	}
+	return newErr
+}
+
 func indexOf(element common.PublicKey, data []common.PublicKey) int {
 	for k, v := range data {
 		if element == v {
@@ -549,98 +807,337 @@ func GetUniqueSigners(ins []solPTypes.Instruction) []string {
 	return signers
 }
 
+// transactionSigners returns the signers of instructions after the fee
+// payer, who signs first. Without a fee payer the first signer pays.
+func transactionSigners(feePayer common.PublicKey, instructions []solPTypes.Instruction) []string {
+	signers := GetUniqueSigners(instructions)
+	if feePayer == (common.PublicKey{}) {
+		return signers
+	}
+	payer := feePayer.ToBase58()
+	ordered := []string{payer}
+	for _, signer := range signers {
+		if signer != payer {
+			ordered = append(ordered, signer)
+		}
+	}
+	return ordered
+}
+
 func ToInstructions(ops []*types.Operation, meta ConstructionMetadata) (common.PublicKey, []solPTypes.Instruction, *types.Error) {
 	log.Printf("START ToInstructions")
-	var instructions []solPTypes.Instruction
-	var feePayer common.PublicKey
-	var matchedOperationHashMap = make(map[int64]bool)
-
-	for _, op := range ops {
-		LogOperation(op)
-		var cont bool
-		var matched *types.Operation
-		cont, matched = FindMatch(ops, op, matchedOperationHashMap)
-		if cont {
-			log.Printf("op is alread in matchedOperationHashMap -> continue with next entry")
-			continue
-		}
+	feePayer, built, err := buildOperations(ops, meta)
+	if err != nil {
+		return common.PublicKey{}, nil, err
+	}
+	instructions := joinInstructions(built)
 
-		if matched == nil && op.Amount != nil {
-			return common.PublicKey{}, nil, wrapErr(ErrUnableToParseIntermediateResult, fmt.Errorf("Invalid Operation Request. Please check format"))
+	log.Printf("There are in total %v instructions", len(instructions))
+	for i, in := range instructions {
+		log.Printf("instruction with i=%v", i)
+		log.Printf("in.ProgramID=%v", in.ProgramID.ToBase58())
+		if (in.Accounts != nil) && (len(in.Accounts) > 0) {
+			for _, account := range in.Accounts {
+				log.Printf("account.PubKey=%v, IsSigner=%v, IsWritable=%v", account.PubKey.ToBase58(), account.IsSigner, account.IsWritable)
+			}
 		}
+		log.Printf("in.Data=%v", in.Data)
+	}
 
-		opCopy, err := copystructure.Copy(*op)
-		if err != nil {
-			return common.PublicKey{}, nil, wrapErr(ErrUnclearIntent, fmt.Errorf("Cannot deep copy operations"))
-
-		}
-		tp := opCopy.(types.Operation)
-		tmpOP := &tp
+	log.Printf("END ToInstructions")
+	return feePayer, instructions, nil
+}
 
-		if tmpOP.Metadata == nil {
-			tmpOP.Metadata = make(map[string]interface{})
-		}
-		if matched != nil {
-			fromOp := tmpOP
-			fromAdd := fromOp.Account.Address
-			toOp := matched
-			toAdd := toOp.Account.Address
-			tmpOP.Account = fromOp.Account
-			tmpOP.Metadata["source"] = fromAdd
-			tmpOP.Metadata["destination"] = toAdd
-			tmpOP.Amount = toOp.Amount
+// builtOperation is a merged operation and the instructions built for it.
+type builtOperation struct {
+	op           *types.Operation
+	instructions []solPTypes.Instruction
+}
 
-			matchedOperationHashMap[fromOp.OperationIdentifier.Index] = true
-			matchedOperationHashMap[toOp.OperationIdentifier.Index] = true
+// buildOperations validates and merges ops and builds the instructions of
+// each merged operation.
+func buildOperations(ops []*types.Operation, meta ConstructionMetadata) (common.PublicKey, []builtOperation, *types.Error) {
+	var built []builtOperation
+	var feePayer common.PublicKey
 
-		} else {
-			matchedOperationHashMap[op.OperationIdentifier.Index] = true
+	if meta.FeePayer != "" {
+		if !operations.IsAddress(meta.FeePayer) {
+			return common.PublicKey{}, nil, wrapErr(ErrFeePayerInvalid, fmt.Errorf("%s is not a valid public key", meta.FeePayer))
 		}
+		feePayer = common.PublicKeyFromString(meta.FeePayer)
+	}
+	if err := validateOperations(ops); err != nil {
+		return common.PublicKey{}, nil, err
+	}
+	merged, mergeErr := mergeOperations(ops)
+	if mergeErr != nil {
+		return common.PublicKey{}, nil, mergeErr
+	}
+	for _, tmpOP := range merged {
+		LogOperation(tmpOP)
+		var instructions []solPTypes.Instruction
 
 		log.Printf("tmpOP.Type=%s\n", tmpOP.Type)
+		index := tmpOP.OperationIdentifier.Index
 		switch strings.Split(tmpOP.Type, stypes.Separator)[0] {
 		case "System":
 			s := operations.SystemOperationMetadata{}
-			s.SetMeta(tmpOP, meta.PriorityFee)
+			if err := s.SetMeta(tmpOP, meta.PriorityFee, meta.Nonce); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			if err := s.Validate(tmpOP.Type); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
 			instructions = append(instructions, s.ToInstructions(tmpOP.Type)...)
 			break
 		case "SplToken":
 			s := operations.SplTokenOperationMetadata{}
-			s.SetMeta(tmpOP, meta.SplTokenAccMapKey)
+			if err := s.SetMeta(tmpOP, meta.SplTokenAccMapKey); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			if err := s.Validate(tmpOP.Type); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
 			instructions = append(instructions, s.ToInstructions(tmpOP.Type)...)
 			break
 		case "SplAssociatedTokenAccount":
 			s := operations.SplAssociatedTokenAccountOperationMetadata{}
-			s.SetMeta(tmpOP)
+			if err := s.SetMeta(tmpOP); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			if err := s.Validate(tmpOP.Type); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
 			instructions = append(instructions, s.ToInstructions(tmpOP.Type)...)
 			break
 		case "Stake":
 			s := operations.StakeOperationMetadata{}
-			s.SetMeta(tmpOP, meta.PriorityFee)
-			instructions = append(instructions, s.ToInstructions(tmpOP.Type)...)
+			if err := s.SetMeta(tmpOP, meta.PriorityFee, meta.StakeRentExempt); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			if err := s.Validate(tmpOP.Type); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			stakeInstructions, err := s.ToInstructions(tmpOP.Type)
+			if err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			instructions = append(instructions, stakeInstructions...)
 			if tmpOP.Type == stypes.Stake__WithdrawStake && s.FeePayer != "" {
+				if meta.FeePayer != "" && s.FeePayer != meta.FeePayer {
+					return common.PublicKey{}, nil, operationErr(index, operations.FieldErrorf("metadata.feePayer", "differs from fee_payer %s of the transaction", meta.FeePayer))
+				}
 				feePayer = common.PublicKeyFromString(s.FeePayer)
 			}
 			break
+		case "Memo":
+			s := operations.MemoOperationMetadata{}
+			if err := s.SetMeta(tmpOP); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			if err := s.Validate(tmpOP.Type); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			instructions = append(instructions, s.ToInstructions(tmpOP.Type)...)
+			break
+		case "Generic":
+			s := operations.GenericOperationMetadata{}
+			if err := s.SetMeta(tmpOP); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			if err := s.Validate(tmpOP.Type); err != nil {
+				return common.PublicKey{}, nil, operationErr(index, err)
+			}
+			// the account of the operation is one that signs the instruction
+			if tmpOP.Account != nil && !s.IsSigner(tmpOP.Account.Address) {
+				return common.PublicKey{}, nil, operationErr(index, operations.FieldErrorf("account", "%s is not a signer of the instruction", tmpOP.Account.Address))
+			}
+			instructions = append(instructions, s.ToInstructions(tmpOP.Type)...)
+			break
 		default:
-			return common.PublicKey{}, nil, wrapErr(ErrUnableToParseIntermediateResult, fmt.Errorf("Operation not implemented for construction"))
+			return common.PublicKey{}, nil, operationErr(index, operations.FieldErrorf("type", "%s is not supported for construction", tmpOP.Type))
 		}
+		built = append(built, builtOperation{op: tmpOP, instructions: instructions})
 	}
+	return feePayer, built, nil
+}
 
-	log.Printf("There are in total %v instructions", len(instructions))
-	for i, in := range instructions {
-		log.Printf("instruction with i=%v", i)
-		log.Printf("in.ProgramID=%v", in.ProgramID.ToBase58())
-		if (in.Accounts != nil) && (len(in.Accounts) > 0) {
-			for _, account := range in.Accounts {
-				log.Printf("account.PubKey=%v, IsSigner=%v, IsWritable=%v", account.PubKey.ToBase58(), account.IsSigner, account.IsWritable)
+// joinInstructions concatenates the instructions of built. Compute budget
+// instructions and idempotent creations of an associated token account
+// take effect once per transaction, so only the first of each is kept.
+func joinInstructions(built []builtOperation) []solPTypes.Instruction {
+	var instructions []solPTypes.Instruction
+	for _, b := range built {
+		for _, in := range b.instructions {
+			if onceInstruction(in) && containsInstruction(instructions, in) {
+				continue
 			}
+			instructions = append(instructions, in)
 		}
-		log.Printf("in.Data=%v", in.Data)
 	}
+	return instructions
+}
 
-	log.Printf("END ToInstructions")
-	return feePayer, instructions, nil
+func onceInstruction(in solPTypes.Instruction) bool {
+	return in.ProgramID == common.ComputeBudgetProgramID ||
+		in.ProgramID == common.SPLAssociatedTokenAccountProgramID && bytes.Equal(in.Data, []byte{byte(associated_token_account.InstructionCreateIdempotent)})
+}
+
+func containsInstruction(instructions []solPTypes.Instruction, in solPTypes.Instruction) bool {
+	for _, v := range instructions {
+		if sameInstruction(v, in) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxTransactionSize is the largest serialized transaction the network
+// accepts, the size of a packet less its IP and UDP headers.
+const maxTransactionSize = 1232
+
+// checkTransactionSize returns ErrTransactionTooLarge if the signed
+// transaction of built is larger than maxTransactionSize, with the number
+// of its transfers that fit in one transaction.
+func checkTransactionSize(feePayer common.PublicKey, withNonce stypes.WithNonce, built []builtOperation) *types.Error {
+	size, err := transactionSize(feePayer, withNonce, built)
+	if err != nil {
+		return wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+	if size <= maxTransactionSize {
+		return nil
+	}
+
+	transfers := 0
+	for _, b := range built {
+		if solanago.Contains(batchTypes, b.op.Type) {
+			transfers++
+		}
+	}
+	// the other operations are kept with as many transfers as fit
+	fit := 0
+	for fit < transfers {
+		var kept []builtOperation
+		n := 0
+		for _, b := range built {
+			if solanago.Contains(batchTypes, b.op.Type) {
+				if n > fit {
+					continue
+				}
+				n++
+			}
+			kept = append(kept, b)
+		}
+		if size, err := transactionSize(feePayer, withNonce, kept); err != nil || size > maxTransactionSize {
+			break
+		}
+		fit++
+	}
+
+	if transfers == 0 {
+		newErr := wrapErr(ErrTransactionTooLarge, fmt.Errorf("transaction is %d bytes, more than the limit of %d", size, maxTransactionSize))
+		newErr.Details["size"] = size
+		newErr.Details["max_size"] = maxTransactionSize
+		return newErr
+	}
+	newErr := wrapErr(ErrTransactionTooLarge, fmt.Errorf("transaction is %d bytes, more than the limit of %d: %d of its %d transfers fit in one transaction", size, maxTransactionSize, fit, transfers))
+	newErr.Details["size"] = size
+	newErr.Details["max_size"] = maxTransactionSize
+	newErr.Details["transfers"] = transfers
+	newErr.Details["max_transfers"] = fit
+	return newErr
+}
+
+// transactionSize returns the size of the signed transaction of built,
+// paid by feePayer or else by its first signer.
+func transactionSize(feePayer common.PublicKey, withNonce stypes.WithNonce, built []builtOperation) (int, error) {
+	instructions := joinInstructions(built)
+	if signers := GetUniqueSigners(instructions); feePayer == (common.PublicKey{}) && len(signers) > 0 {
+		feePayer = common.PublicKeyFromString(signers[0])
+	}
+	message := solPTypes.NewMessage(solPTypes.NewMessageParam{
+		FeePayer:        feePayer,
+		Instructions:    AdvanceNonce(withNonce, instructions),
+		RecentBlockhash: common.PublicKey{}.ToBase58(),
+	})
+	tx := solPTypes.Transaction{Message: message}
+	for i := 0; i < int(message.Header.NumRequireSignatures); i++ {
+		tx.Signatures = append(tx.Signatures, make([]byte, 64))
+	}
+	b, err := tx.Serialize()
+	return len(b), err
+}
+
+// NonceOptionsFromOperations collects the nonce accounts used by ops so that
+// /construction/metadata can fetch and validate them.
+func NonceOptionsFromOperations(ops []*types.Operation) (stypes.NonceOptions, *types.Error) {
+	options := stypes.NonceOptions{Accounts: make(map[string]string)}
+	merged, err := mergeOperations(ops)
+	if err != nil {
+		return options, err
+	}
+	for _, tmpOP := range merged {
+		switch tmpOP.Type {
+		case stypes.System__CreateNonceAccount:
+			options.RentExempt = true
+		case stypes.System__AdvanceNonce, stypes.System__WithdrawFromNonce, stypes.System__AuthorizeNonce, stypes.System__CloseNonceAccount:
+			s := operations.SystemOperationMetadata{}
+			if err := s.SetMeta(tmpOP, stypes.PriorityFee{}, stypes.NonceMetadata{}); err != nil {
+				return options, operationErr(tmpOP.OperationIdentifier.Index, err)
+			}
+			account := s.NonceAccountAddress(tmpOP.Type)
+			authority, _ := tmpOP.Metadata["authority"].(string)
+			if _, ok := options.Accounts[account]; !ok || authority != "" {
+				options.Accounts[account] = authority
+			}
+		}
+	}
+	return options, nil
+}
+
+// StakeRentExemptFromOperations reports whether any operation creates a
+// stake account whose rent-exempt balance has to be fetched.
+func StakeRentExemptFromOperations(ops []*types.Operation) bool {
+	for _, op := range ops {
+		if op.Type == stypes.Stake__SplitWithSeed {
+			return true
+		}
+	}
+	return false
+}
+
+// getNonceMetadata fetches every nonce account in options, checks that it is
+// initialized and controlled by the requested authority and resolves the
+// rent-exempt balance for new nonce accounts.
+func (s *ConstructionAPIService) getNonceMetadata(ctx context.Context, options stypes.NonceOptions) (stypes.NonceMetadata, *types.Error) {
+	nonceMeta := stypes.NonceMetadata{Accounts: make(map[string]stypes.NonceAccount)}
+	for account, authority := range options.Accounts {
+		nonceAccount, err := s.directClient.GetNonceAccount(ctx, account)
+		if err != nil {
+			return nonceMeta, wrapNonceErr(err)
+		}
+		if authority != "" && authority != nonceAccount.Authority {
+			return nonceMeta, wrapErr(ErrNonceAccountInvalid, fmt.Errorf("%s is not the authority of nonce account %s", authority, account))
+		}
+		nonceMeta.Accounts[account] = nonceAccount
+	}
+	if options.RentExempt {
+		rent, err := s.client.Rpc.GetMinimumBalanceForRentExemption(ctx, system.NonceAccountSize)
+		if err != nil {
+			return nonceMeta, wrapErr(ErrGeth, err)
+		}
+		nonceMeta.RentExemptLamports = rent
+	}
+	return nonceMeta, nil
+}
+
+// wrapNonceErr reports invalid nonce accounts as ErrNonceAccountInvalid and
+// anything else as a node error.
+func wrapNonceErr(err error) *types.Error {
+	if errors.Is(err, solanago.ErrInvalidNonceAccount) {
+		return wrapErr(ErrNonceAccountInvalid, err)
+	}
+	return wrapErr(ErrGeth, err)
 }
 
 func p(a string) common.PublicKey {
@@ -2,18 +2,33 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"crypto/ed25519"
 
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	solPTypes "github.com/blocto/solana-go-sdk/types"
+	"github.com/coinbase/rosetta-sdk-go/parser"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/imerkle/rosetta-solana-go/configuration"
 	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	"github.com/imerkle/rosetta-solana-go/solana/operations"
+	"github.com/imerkle/rosetta-solana-go/solana/parse"
+	"github.com/mr-tron/base58"
 	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
 )
 
 func TestConstructionServiceSpl(t *testing.T) {
@@ -276,3 +291,1432 @@ func constructionPipe(t *testing.T, ops []*types.Operation, submit bool) {
 		fmt.Println(submitRes.TransactionIdentifier.Hash)
 	}
 }
+
+func TestNonceOperations(t *testing.T) {
+	nonceAccount := "42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v"
+	authority := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	destination := "CZDpZ7KeMansnszdEGZ55C4HjGsMSQBzxPu6jqRm6ZrU"
+
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{
+			Index: 0,
+		},
+		Type: stypes.System__CloseNonceAccount,
+		Account: &types.AccountIdentifier{
+			Address: nonceAccount,
+		},
+		Metadata: map[string]interface{}{
+			"destination": destination,
+		},
+	}}
+
+	options, err := NonceOptionsFromOperations(ops)
+	assert.Assert(t, err == nil)
+	assert.DeepEqual(t, map[string]string{nonceAccount: ""}, options.Accounts)
+
+	meta := ConstructionMetadata{
+		Nonce: stypes.NonceMetadata{
+			Accounts: map[string]stypes.NonceAccount{
+				nonceAccount: {Authority: authority, Lamports: 1447680},
+			},
+		},
+	}
+	_, instructions, err := ToInstructions(ops, meta)
+	assert.Assert(t, err == nil)
+	assert.Equal(t, 1, len(instructions))
+	assert.Equal(t, nonceAccount, instructions[0].Accounts[0].PubKey.ToBase58())
+	assert.Equal(t, destination, instructions[0].Accounts[1].PubKey.ToBase58())
+	assert.DeepEqual(t, []string{authority}, GetUniqueSigners(instructions))
+}
+
+func TestWrapSolOperations(t *testing.T) {
+	owner := &types.AccountIdentifier{
+		Address: "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH",
+	}
+	cSol := &types.Currency{
+		Symbol:   stypes.Currency.Symbol,
+		Decimals: stypes.Currency.Decimals,
+	}
+	wrapped, _, _ := common.FindAssociatedTokenAddress(common.PublicKeyFromString(owner.Address), common.PublicKeyFromString(stypes.NativeMint))
+
+	wrapOps := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                stypes.SplToken__WrapSol,
+		Account:             owner,
+		Amount:              &types.Amount{Value: "-1000", Currency: cSol},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                stypes.SplToken__WrapSol,
+		Account:             owner,
+		Amount:              &types.Amount{Value: "1000", Currency: cSol},
+	}}
+	_, instructions, err := ToInstructions(wrapOps, ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	assert.Equal(t, 3, len(instructions))
+	assert.Equal(t, wrapped, instructions[0].Accounts[1].PubKey)
+	assert.Equal(t, wrapped, instructions[1].Accounts[1].PubKey)
+	assert.Equal(t, wrapped, instructions[2].Accounts[0].PubKey)
+
+	unwrapOps := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                stypes.SplToken__UnwrapSol,
+		Account:             owner,
+	}}
+	_, instructions, err = ToInstructions(unwrapOps, ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	assert.Equal(t, 1, len(instructions))
+	assert.Equal(t, wrapped, instructions[0].Accounts[0].PubKey)
+	assert.Equal(t, owner.Address, instructions[0].Accounts[1].PubKey.ToBase58())
+	assert.DeepEqual(t, []string{owner.Address}, GetUniqueSigners(instructions))
+}
+
+func TestSeededSystemOperations(t *testing.T) {
+	base := &types.AccountIdentifier{
+		Address: "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH",
+	}
+	seeded := common.CreateWithSeed(common.PublicKeyFromString(base.Address), "deposit-1", common.SystemProgramID)
+	cSol := &types.Currency{
+		Symbol:   stypes.Currency.Symbol,
+		Decimals: stypes.Currency.Decimals,
+	}
+
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                stypes.System__CreateAccountWithSeed,
+		Account:             base,
+		Amount:              &types.Amount{Value: "-890880", Currency: cSol},
+		Metadata:            map[string]interface{}{"seed": "deposit-1"},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                stypes.System__CreateAccountWithSeed,
+		Account:             &types.AccountIdentifier{Address: seeded.ToBase58()},
+		Amount:              &types.Amount{Value: "890880", Currency: cSol},
+		Metadata:            map[string]interface{}{"seed": "deposit-1"},
+	}}
+	_, instructions, err := ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	assert.Equal(t, 1, len(instructions))
+	assert.Equal(t, seeded, instructions[0].Accounts[1].PubKey)
+	assert.DeepEqual(t, []string{base.Address}, GetUniqueSigners(instructions))
+
+	parsed, parseErr := parse.ParseInstruction(instructions[0])
+	assert.Assert(t, parseErr == nil)
+	assert.Equal(t, "deposit-1", parsed.Parsed.Info["seed"])
+
+	ops[1].Account = base
+	_, _, err = ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, err != nil)
+}
+
+func TestSystemAccountOwner(t *testing.T) {
+	from := &types.AccountIdentifier{
+		Address: "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH",
+	}
+	newAccount := "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                stypes.System__CreateAccount,
+		Account:             from,
+		Metadata: map[string]interface{}{
+			"destination": newAccount,
+			"lamports":    1000000,
+			"space":       165,
+		},
+	}}
+	_, instructions, err := ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	parsed, parseErr := parse.ParseInstruction(instructions[0])
+	assert.Assert(t, parseErr == nil)
+	assert.Equal(t, common.TokenProgramID.ToBase58(), parsed.Parsed.Info["owner"])
+
+	ops[0].Metadata["owner"] = common.StakeProgramID.ToBase58()
+	_, instructions, err = ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	parsed, parseErr = parse.ParseInstruction(instructions[0])
+	assert.Assert(t, parseErr == nil)
+	assert.Equal(t, common.StakeProgramID.ToBase58(), parsed.Parsed.Info["owner"])
+
+	ops[0].Type = stypes.System__Assign
+	_, instructions, err = ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	parsed, parseErr = parse.ParseInstruction(instructions[0])
+	assert.Assert(t, parseErr == nil)
+	assert.Equal(t, common.StakeProgramID.ToBase58(), parsed.Parsed.Info["owner"])
+
+	ops[0].Metadata["owner"] = "not-a-program"
+	_, _, err = ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, err != nil)
+	assert.Equal(t, ErrUnclearIntent.Code, err.Code)
+}
+
+func TestStakeManagementOperations(t *testing.T) {
+	authority := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	stakeAccount := "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"
+	newAuthority := "8ZoRBx7LmMzhkUWYKMRwd2MSHzF6LCbqD8UVnwXBpV5F"
+	newStake := "DzPXN6YUKVNhRsYjoXqZyo7yPzuq9NxZyzgyYBoP3AGS"
+	custodian := "7RCz8wb6WXxUhAigok9ttgrVgDFFFbibcirECzWSBauM"
+	vote := "9QU2QSxhb24FUX3Tu2FpczXjpK3VYrvRudywSZaM29mF"
+
+	tests := []struct {
+		opType   string
+		metadata map[string]interface{}
+		data     []byte
+		signers  []string
+	}{{
+		opType:   stypes.Stake__AuthorizeChecked,
+		metadata: map[string]interface{}{"stake": stakeAccount, "newAuthority": newAuthority, "stakeAuthorizationType": 1},
+		data:     []byte{10, 0, 0, 0, 1, 0, 0, 0},
+		signers:  []string{authority, newAuthority},
+	}, {
+		opType:   stypes.Stake__AuthorizeWithSeed,
+		metadata: map[string]interface{}{"stake": stakeAccount, "newAuthority": newAuthority, "authoritySeed": "s"},
+		data:     []byte{8, 0, 0, 0},
+		signers:  []string{authority},
+	}, {
+		opType:   stypes.Stake__SetLockup,
+		metadata: map[string]interface{}{"stake": stakeAccount, "lockupEpoch": 5},
+		data:     []byte{6, 0, 0, 0, 0, 1, 5, 0, 0, 0, 0, 0, 0, 0, 0},
+		signers:  []string{authority},
+	}, {
+		opType:   stypes.Stake__SetLockupChecked,
+		metadata: map[string]interface{}{"stake": stakeAccount, "lockupUnixTimestamp": 1, "lockupCustodian": custodian},
+		data:     []byte{12, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+		signers:  []string{authority, custodian},
+	}, {
+		// a lockup given as 0 is cleared, not left unchanged
+		opType:   stypes.Stake__SetLockup,
+		metadata: map[string]interface{}{"stake": stakeAccount, "lockupUnixTimestamp": 0},
+		data:     []byte{6, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		signers:  []string{authority},
+	}, {
+		opType:   stypes.Stake__SetLockupChecked,
+		metadata: map[string]interface{}{"stake": stakeAccount, "lockupEpoch": 0},
+		data:     []byte{12, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+		signers:  []string{authority},
+	}, {
+		opType:   stypes.Stake__InitializeChecked,
+		metadata: map[string]interface{}{"stake": stakeAccount, "withdrawer": newAuthority},
+		data:     []byte{9, 0, 0, 0},
+		signers:  []string{newAuthority},
+	}, {
+		opType:   stypes.Stake__Redelegate,
+		metadata: map[string]interface{}{"stake": stakeAccount, "redelegateDestination": newStake, "voteAccount": vote},
+		data:     []byte{15, 0, 0, 0},
+		signers:  []string{newStake, authority},
+	}}
+	for _, test := range tests {
+		ops := []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                test.opType,
+			Account:             &types.AccountIdentifier{Address: authority},
+			Metadata:            test.metadata,
+		}}
+		_, instructions, err := ToInstructions(ops, ConstructionMetadata{})
+		assert.Assert(t, err == nil, test.opType)
+		last := instructions[len(instructions)-1]
+		assert.Equal(t, common.StakeProgramID, last.ProgramID, test.opType)
+		assert.DeepEqual(t, test.data, last.Data[:len(test.data)])
+		assert.DeepEqual(t, test.signers, GetUniqueSigners(instructions))
+	}
+}
+
+func TestSplitStakeWithSeed(t *testing.T) {
+	staker := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	stakeAccount := "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"
+	splitStake := common.CreateWithSeed(common.PublicKeyFromString(staker), "unstake-1", common.StakeProgramID)
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                stypes.Stake__SplitWithSeed,
+		Account:             &types.AccountIdentifier{Address: staker},
+		Metadata:            map[string]interface{}{"stake": stakeAccount, "splitSeed": "unstake-1", "lamports": 1000000000},
+	}}
+	assert.Assert(t, StakeRentExemptFromOperations(ops))
+
+	_, instructions, err := ToInstructions(ops, ConstructionMetadata{StakeRentExempt: 2282880})
+	assert.Assert(t, err == nil)
+	assert.Equal(t, 2, len(instructions))
+	assert.DeepEqual(t, []string{staker}, GetUniqueSigners(instructions))
+
+	parsed, parseErr := parse.ParseInstruction(instructions[0])
+	assert.Assert(t, parseErr == nil)
+	assert.Equal(t, "createAccountWithSeed", parsed.Parsed.InstructionType)
+	assert.Equal(t, splitStake.ToBase58(), parsed.Parsed.Info["newAccount"])
+	assert.Equal(t, uint64(2282880), parsed.Parsed.Info["lamports"])
+	assert.Equal(t, common.StakeProgramID.ToBase58(), parsed.Parsed.Info["owner"])
+	assert.Equal(t, splitStake, instructions[1].Accounts[1].PubKey)
+
+	ops[0].Metadata["splitDestination"] = stakeAccount
+	_, _, err = ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, err != nil)
+}
+
+func TestSubAccountConstruction(t *testing.T) {
+	owner := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	sourceToken := "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV"
+	destinationToken := "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L"
+	currency := &types.Currency{Symbol: "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr", Decimals: 2}
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                stypes.SplToken__Transfer,
+		Account:             solanago.TokenAccountIdentifier(owner, sourceToken),
+		Amount:              &types.Amount{Value: "-1", Currency: currency},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                stypes.SplToken__Transfer,
+		Account:             solanago.TokenAccountIdentifier("CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n", destinationToken),
+		Amount:              &types.Amount{Value: "1", Currency: currency},
+	}}
+	_, instructions, err := ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	assert.Equal(t, 1, len(instructions))
+	assert.Equal(t, sourceToken, instructions[0].Accounts[0].PubKey.ToBase58())
+	assert.Equal(t, destinationToken, instructions[0].Accounts[2].PubKey.ToBase58())
+	assert.DeepEqual(t, []string{owner}, GetUniqueSigners(instructions))
+
+	stakeAccount := "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"
+	ops = []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                stypes.Stake__DeactivateStake,
+		Account:             solanago.StakeAccountIdentifier(owner, stakeAccount),
+	}}
+	_, instructions, err = ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	assert.Equal(t, stakeAccount, instructions[0].Accounts[0].PubKey.ToBase58())
+	assert.DeepEqual(t, []string{owner}, GetUniqueSigners(instructions))
+}
+
+func TestSubmitMetadataMiddleware(t *testing.T) {
+	var got map[string]interface{}
+	var body string
+	handler := submitMetadataMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = submitMetadata(r.Context())
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+
+	req := `{"signed_transaction":"abc","metadata":{"skip_preflight":true,"wait_for":"confirmed"}}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/construction/submit", strings.NewReader(req)))
+	assert.Equal(t, req, body)
+	opts, err := solanago.GetSubmitOptions(got)
+	assert.NilError(t, err)
+	assert.Equal(t, true, opts.SkipPreflight)
+	assert.Equal(t, "confirmed", opts.WaitFor)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/construction/hash", strings.NewReader(req)))
+	assert.Assert(t, got == nil)
+}
+
+func TestWrapSubmitErr(t *testing.T) {
+	tx := solPTypes.Transaction{}
+	err := wrapSubmitErr(&rpc.JsonRpcError{
+		Code:    -32002,
+		Message: "Transaction simulation failed: Blockhash not found",
+		Data:    map[string]interface{}{"err": "BlockhashNotFound", "logs": []interface{}{}},
+	}, tx)
+	assert.Equal(t, ErrBlockhashNotFound.Code, err.Code)
+	assert.Equal(t, true, err.Retriable)
+
+	err = wrapSubmitErr(&rpc.JsonRpcError{
+		Code:    -32002,
+		Message: "Transaction simulation failed: Error processing Instruction 1: custom program error: 0x10",
+		Data: map[string]interface{}{
+			"err":  map[string]interface{}{"InstructionError": []interface{}{float64(1), map[string]interface{}{"Custom": float64(16)}}},
+			"logs": []string{"Program log: Error: custom"},
+		},
+	}, tx)
+	assert.Equal(t, ErrSimulationFailed.Code, err.Code)
+	assert.Equal(t, 1, err.Details["instruction_index"])
+	assert.Equal(t, uint32(16), err.Details["custom_code"])
+	assert.DeepEqual(t, []string{"Program log: Error: custom"}, err.Details["logs"])
+
+	err = wrapSubmitErr(errors.New("connection refused"), tx)
+	assert.Equal(t, ErrBroadcastFailed.Code, err.Code)
+}
+
+// newTwoSignerTransaction returns an unsigned transfer signed by its
+// sender and a separate fee payer.
+func newTwoSignerTransaction(t *testing.T) (solPTypes.Transaction, ed25519.PrivateKey, ed25519.PrivateKey) {
+	payer := ed25519.NewKeyFromSeed(make([]byte, 32))
+	fromSeed := make([]byte, 32)
+	fromSeed[0] = 1
+	from := ed25519.NewKeyFromSeed(fromSeed)
+
+	tx, err := solPTypes.NewTransaction(solPTypes.NewTransactionParam{
+		Message: solPTypes.NewMessage(solPTypes.NewMessageParam{
+			FeePayer:        common.PublicKeyFromBytes(payer.Public().(ed25519.PublicKey)),
+			RecentBlockhash: "9rAtxuhtKn8qagc3UtZFyhLrw5zgh6rLakLbHbQTGPFi",
+			Instructions: []solPTypes.Instruction{
+				system.Transfer(system.TransferParam{
+					From:   common.PublicKeyFromBytes(from.Public().(ed25519.PublicKey)),
+					To:     p("42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v"),
+					Amount: 1,
+				}),
+			},
+		}),
+	})
+	assert.NilError(t, err)
+	return tx, payer, from
+}
+
+func TestConstructionCombineVerifiesSignatures(t *testing.T) {
+	tx, payer, from := newTwoSignerTransaction(t)
+	fromKey := common.PublicKeyFromBytes(from.Public().(ed25519.PublicKey))
+	rawTx, err := tx.Serialize()
+	assert.NilError(t, err)
+	message, err := tx.Message.Serialize()
+	assert.NilError(t, err)
+
+	signature := func(key ed25519.PrivateKey, payload []byte) *types.Signature {
+		return &types.Signature{
+			SigningPayload: &types.SigningPayload{
+				AccountIdentifier: &types.AccountIdentifier{Address: base58.Encode(key.Public().(ed25519.PublicKey))},
+				Bytes:             message,
+				SignatureType:     types.Ed25519,
+			},
+			PublicKey:     &types.PublicKey{Bytes: key.Public().(ed25519.PublicKey), CurveType: types.Edwards25519},
+			SignatureType: types.Ed25519,
+			Bytes:         ed25519.Sign(key, payload),
+		}
+	}
+	service := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	combine := func(sigs ...*types.Signature) (*types.ConstructionCombineResponse, *types.Error) {
+		return service.ConstructionCombine(context.Background(), &types.ConstructionCombineRequest{
+			UnsignedTransaction: base58.Encode(rawTx),
+			Signatures:          sigs,
+		})
+	}
+
+	res, rErr := combine(signature(payer, message), signature(from, message))
+	assert.Assert(t, rErr == nil)
+	signedTx, err := solanago.GetTxFromStr(res.SignedTransaction)
+	assert.NilError(t, err)
+	assert.Assert(t, verifySignatures(signedTx, message, true) == nil)
+
+	_, rErr = combine(signature(payer, message), signature(from, []byte("other message")))
+	assert.Equal(t, ErrSignatureInvalid.Code, rErr.Code)
+	assert.Equal(t, fromKey.ToBase58(), rErr.Details["public_key"])
+
+	other := ed25519.NewKeyFromSeed(append([]byte{2}, make([]byte, 31)...))
+	_, rErr = combine(signature(payer, message), signature(from, message), signature(other, message))
+	assert.Equal(t, ErrSignatureInvalid.Code, rErr.Code)
+	assert.Equal(t, base58.Encode(other.Public().(ed25519.PublicKey)), rErr.Details["public_key"])
+
+	_, rErr = combine(signature(payer, message))
+	assert.Equal(t, ErrSignatureInvalid.Code, rErr.Code)
+	assert.DeepEqual(t, []string{fromKey.ToBase58()}, rErr.Details["missing_signers"])
+
+	ecdsa := signature(from, message)
+	ecdsa.SignatureType = types.Ecdsa
+	_, rErr = combine(signature(payer, message), ecdsa)
+	assert.Equal(t, ErrSignatureInvalid.Code, rErr.Code)
+	assert.Equal(t, fromKey.ToBase58(), rErr.Details["public_key"])
+	assert.Equal(t, types.Ecdsa, rErr.Details["signature_type"])
+}
+
+func TestConstructionParseSigned(t *testing.T) {
+	tx, payer, from := newTwoSignerTransaction(t)
+	message, err := tx.Message.Serialize()
+	assert.NilError(t, err)
+	service := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	parseTx := func(tx solPTypes.Transaction, signed bool) (*types.ConstructionParseResponse, *types.Error) {
+		rawTx, err := tx.Serialize()
+		assert.NilError(t, err)
+		return service.ConstructionParse(context.Background(), &types.ConstructionParseRequest{
+			Signed:      signed,
+			Transaction: base58.Encode(rawTx),
+		})
+	}
+
+	res, rErr := parseTx(tx, false)
+	assert.Assert(t, rErr == nil)
+	assert.Equal(t, 0, len(res.AccountIdentifierSigners))
+	assert.Equal(t, 2, len(res.Operations))
+
+	_, rErr = parseTx(tx, true)
+	assert.Equal(t, ErrSignatureInvalid.Code, rErr.Code)
+	assert.Equal(t, 2, len(rErr.Details["missing_signers"].([]string)))
+
+	tx.Signatures[0] = ed25519.Sign(payer, message)
+	tx.Signatures[1] = ed25519.Sign(from, []byte("other message"))
+	_, rErr = parseTx(tx, false)
+	assert.Equal(t, ErrSignatureInvalid.Code, rErr.Code)
+	assert.Equal(t, base58.Encode(from.Public().(ed25519.PublicKey)), rErr.Details["public_key"])
+
+	tx.Signatures[1] = ed25519.Sign(from, message)
+	res, rErr = parseTx(tx, true)
+	assert.Assert(t, rErr == nil)
+	assert.Equal(t, 2, len(res.AccountIdentifierSigners))
+}
+
+// jsonValue is metadata as it is sent in JSON, nil if it is empty.
+func jsonValue(t *testing.T, metadata map[string]interface{}) interface{} {
+	if len(metadata) == 0 {
+		return nil
+	}
+	j, err := json.Marshal(metadata)
+	assert.NilError(t, err)
+	var value interface{}
+	assert.NilError(t, json.Unmarshal(j, &value))
+	return value
+}
+
+func TestConstructionParseRoundTrip(t *testing.T) {
+	owner := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	receiver := "42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v"
+	other := "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n"
+	nonceAccount := "CZDpZ7KeMansnszdEGZ55C4HjGsMSQBzxPu6jqRm6ZrU"
+	fromToken := "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV"
+	toToken := "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L"
+	mint := "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr"
+	stakeAccount := "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"
+	newStake := "DzPXN6YUKVNhRsYjoXqZyo7yPzuq9NxZyzgyYBoP3AGS"
+	newAuthority := "8ZoRBx7LmMzhkUWYKMRwd2MSHzF6LCbqD8UVnwXBpV5F"
+	custodian := "7RCz8wb6WXxUhAigok9ttgrVgDFFFbibcirECzWSBauM"
+	vote := "9QU2QSxhb24FUX3Tu2FpczXjpK3VYrvRudywSZaM29mF"
+	seeded := common.CreateWithSeed(p(owner), "seed", common.SystemProgramID).ToBase58()
+
+	sol := &types.Currency{Symbol: stypes.Symbol, Decimals: stypes.Decimals}
+	token := &types.Currency{Symbol: mint, Decimals: 2}
+	account := func(address string) *types.AccountIdentifier {
+		return &types.AccountIdentifier{Address: address}
+	}
+	single := func(opType string, address string, metadata map[string]interface{}) []*types.Operation {
+		return []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                opType,
+			Account:             account(address),
+			Metadata:            metadata,
+		}}
+	}
+	pair := func(opType string, from *types.AccountIdentifier, to *types.AccountIdentifier, value string, currency *types.Currency, metadata map[string]interface{}) []*types.Operation {
+		return []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                opType,
+			Account:             from,
+			Amount:              &types.Amount{Value: "-" + value, Currency: currency},
+			Metadata:            metadata,
+		}, {
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                opType,
+			Account:             to,
+			Amount:              &types.Amount{Value: value, Currency: currency},
+		}}
+	}
+	// one debit of the sum of values relating to a credit of each value
+	batch := func(opType string, from string, to []string, values []int, currency *types.Currency, metadata map[string]interface{}) []*types.Operation {
+		total := 0
+		ops := []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                opType,
+			Account:             account(from),
+			Metadata:            metadata,
+		}}
+		for k, value := range values {
+			total += value
+			ops[0].RelatedOperations = append(ops[0].RelatedOperations, &types.OperationIdentifier{Index: int64(k + 1)})
+			ops = append(ops, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: int64(k + 1)},
+				Type:                opType,
+				Account:             account(to[k]),
+				Amount:              &types.Amount{Value: fmt.Sprint(value), Currency: currency},
+			})
+		}
+		ops[0].Amount = &types.Amount{Value: fmt.Sprint(-total), Currency: currency}
+		return ops
+	}
+	transfer := pair(stypes.System__Transfer, account(owner), account(receiver), "1000", sol, nil)
+	// as /construction/metadata returns it for operations on the nonce account
+	nonce := stypes.NonceMetadata{Accounts: map[string]stypes.NonceAccount{
+		nonceAccount: {Authority: owner, Lamports: 1447680},
+	}}
+
+	tests := []struct {
+		name     string
+		ops      []*types.Operation
+		metadata map[string]interface{}
+		nonce    bool
+		// parsed are the operations returned when they differ from ops
+		parsed []*types.Operation
+	}{
+		{name: "transfer", ops: transfer},
+		{name: "transfer with priority fee", ops: transfer, metadata: map[string]interface{}{
+			stypes.PriorityFeeKey: stypes.PriorityFee{MicroLamports: "100"},
+		}},
+		{name: "transfer with fee payer", ops: transfer, metadata: map[string]interface{}{
+			stypes.FeePayerKey: other,
+		}},
+		{name: "transfer with fee payer and nonce", ops: transfer, metadata: map[string]interface{}{
+			stypes.FeePayerKey:  other,
+			stypes.WithNonceKey: stypes.WithNonce{Account: nonceAccount, Authority: owner},
+		}},
+		{name: "transfer with nonce", ops: transfer, metadata: map[string]interface{}{
+			stypes.WithNonceKey: stypes.WithNonce{Account: nonceAccount, Authority: owner},
+		}},
+		{name: "batch transfer", ops: batch(stypes.System__Transfer, owner, []string{receiver, other}, []int{1, 2}, sol, nil)},
+		{name: "batch transfer with priority fee", ops: batch(stypes.System__Transfer, owner, []string{receiver, other, newStake}, []int{1, 2, 3}, sol, nil), metadata: map[string]interface{}{
+			stypes.PriorityFeeKey: stypes.PriorityFee{MicroLamports: "100"},
+		}},
+		{name: "batch and transfer", ops: append(batch(stypes.System__Transfer, owner, []string{receiver, other}, []int{1, 2}, sol, nil),
+			&types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: 3},
+				Type:                stypes.System__Transfer,
+				Account:             account(receiver),
+				Amount:              &types.Amount{Value: "-5", Currency: sol},
+			}, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: 4},
+				Type:                stypes.System__Transfer,
+				Account:             account(other),
+				Amount:              &types.Amount{Value: "5", Currency: sol},
+			})},
+		{name: "transfer and advance nonce", ops: append(pair(stypes.System__Transfer, account(owner), account(receiver), "1000", sol, nil),
+			&types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: 2},
+				Type:                stypes.System__AdvanceNonce,
+				Account:             account(owner),
+				Metadata:            map[string]interface{}{"nonce_account": nonceAccount},
+			})},
+		{name: "advance nonce", ops: single(stypes.System__AdvanceNonce, owner, map[string]interface{}{"nonce_account": nonceAccount})},
+		{name: "create account", ops: pair(stypes.System__CreateAccount, account(owner), account(receiver), "2039280", sol, map[string]interface{}{"space": 165})},
+		{name: "create account with seed", ops: pair(stypes.System__CreateAccountWithSeed, account(owner), account(seeded), "1000", sol, map[string]interface{}{"seed": "seed", "space": 10})},
+		{name: "transfer with seed", ops: pair(stypes.System__TransferWithSeed, account(seeded), account(receiver), "1000", sol, map[string]interface{}{"base": owner, "seed": "seed"})},
+		{name: "withdraw from nonce", ops: pair(stypes.System__WithdrawFromNonce, account(nonceAccount), account(receiver), "1000", sol, map[string]interface{}{"authority": owner}), nonce: true},
+		{name: "close nonce account", ops: single(stypes.System__CloseNonceAccount, nonceAccount, map[string]interface{}{"destination": receiver}), nonce: true,
+			parsed: pair(stypes.System__WithdrawFromNonce, account(nonceAccount), account(receiver), "1447680", sol, map[string]interface{}{"authority": owner})},
+		{name: "assign", ops: single(stypes.System__Assign, owner, map[string]interface{}{"owner": common.StakeProgramID.ToBase58()})},
+		{name: "allocate", ops: single(stypes.System__Allocate, owner, map[string]interface{}{"space": 10})},
+		{name: "allocate with seed", ops: single(stypes.System__AllocateWithSeed, owner, map[string]interface{}{"seed": "seed", "space": 10})},
+		{name: "assign with seed", ops: single(stypes.System__AssignWithSeed, owner, map[string]interface{}{"seed": "seed", "owner": common.StakeProgramID.ToBase58()})},
+		{name: "authorize nonce", ops: single(stypes.System__AuthorizeNonce, owner, map[string]interface{}{"nonce_account": nonceAccount, "new_authority": receiver})},
+		{name: "create nonce account", ops: single(stypes.System__CreateNonceAccount, owner, map[string]interface{}{"destination": nonceAccount, "lamports": 1447680})},
+		{name: "token transfer checked", ops: pair(stypes.SplToken__TransferChecked, solanago.TokenAccountIdentifier(owner, fromToken), account(toToken), "1", token, nil)},
+		{name: "token transfer new", ops: pair(stypes.SplToken__TransferNew, solanago.TokenAccountIdentifier(owner, fromToken), account(other), "1", token, nil)},
+		{name: "token transfer with system", ops: pair(stypes.SplToken__TransferWithSystem, account(owner), account(other), "1", token, nil)},
+		{name: "token batch transfer with system", ops: batch(stypes.SplToken__TransferWithSystem, owner, []string{receiver, other}, []int{1, 2}, token, nil)},
+		{name: "token batch transfer with system from token account", ops: batch(stypes.SplToken__TransferWithSystem, owner, []string{receiver, other, newStake}, []int{1, 2, 3}, token, map[string]interface{}{"source_token": fromToken})},
+		{name: "token transfer with system from token account", ops: pair(stypes.SplToken__TransferWithSystem, account(owner), account(other), "1", token, map[string]interface{}{"source_token": fromToken})},
+		{name: "token transfer checked by multisig", ops: pair(stypes.SplToken__TransferChecked, solanago.TokenAccountIdentifier(owner, fromToken), account(toToken), "1", token, map[string]interface{}{"signers": []string{receiver, other}})},
+		{name: "token transfer", ops: pair(stypes.SplToken__Transfer, solanago.TokenAccountIdentifier(owner, fromToken), account(toToken), "1", token, nil),
+			parsed: pair(stypes.SplToken__TransferChecked, solanago.TokenAccountIdentifier(owner, fromToken), account(toToken), "1", token, nil)},
+		{name: "token transfer by multisig", ops: pair(stypes.SplToken__Transfer, solanago.TokenAccountIdentifier(owner, fromToken), account(toToken), "1", token, map[string]interface{}{"signers": []string{receiver, other}}),
+			parsed: pair(stypes.SplToken__TransferChecked, solanago.TokenAccountIdentifier(owner, fromToken), account(toToken), "1", token, map[string]interface{}{"signers": []string{receiver, other}})},
+		{name: "token mint to", ops: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                stypes.SplToken__MintTo,
+			Account:             solanago.TokenAccountIdentifier(owner, toToken),
+			Amount:              &types.Amount{Value: "5", Currency: token},
+		}}},
+		{name: "token burn by multisig", ops: []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                stypes.SplToken__Burn,
+			Account:             solanago.TokenAccountIdentifier(owner, fromToken),
+			Amount:              &types.Amount{Value: "-5", Currency: token},
+			Metadata:            map[string]interface{}{"signers": []string{receiver}},
+		}}},
+		{name: "token create account", ops: single(stypes.SplToken__CreateAccount, owner, map[string]interface{}{"destination": toToken, "mint": mint, "amount": 2039280})},
+		{name: "wrap sol", ops: pair(stypes.SplToken__WrapSol, account(owner), account(other), "1000", sol, nil)},
+		{name: "unwrap sol", ops: single(stypes.SplToken__UnwrapSol, owner, nil)},
+		{name: "create associated token account", ops: single(stypes.SplAssociatedTokenAccount__Create, owner, map[string]interface{}{"wallet": receiver, "mint": mint})},
+		{name: "memo", ops: single(stypes.Memo__Memo, owner, map[string]interface{}{"memo": "deposit 1234"})},
+		{name: "generic instruction", ops: single(stypes.Generic__Instruction, owner, map[string]interface{}{
+			"program_id": vote,
+			"accounts": []map[string]interface{}{
+				{"pubkey": owner, "is_signer": true, "is_writable": true},
+				{"pubkey": receiver, "is_writable": true},
+				{"pubkey": other},
+			},
+			"data": "3Bxs4h24hBtQy9rw",
+		})},
+		{name: "create stake account", ops: single(stypes.Stake__CreateStakeAccount, owner, map[string]interface{}{"stake": stakeAccount, "lamports": 1000})},
+		{name: "create stake and delegate", ops: single(stypes.Stake__CreateStakeAndDelegate, owner, map[string]interface{}{"stake": stakeAccount, "lamports": 1000, "voteAccount": vote})},
+		{name: "delegate stake", ops: single(stypes.Stake__DelegateStake, owner, map[string]interface{}{"stake": stakeAccount, "voteAccount": vote})},
+		{name: "delegate stake with priority fee", ops: single(stypes.Stake__DelegateStake, owner, map[string]interface{}{"stake": stakeAccount, "voteAccount": vote}), metadata: map[string]interface{}{
+			stypes.PriorityFeeKey: stypes.PriorityFee{MicroLamports: "100"},
+		}},
+		{name: "deactivate stake", ops: single(stypes.Stake__DeactivateStake, owner, map[string]interface{}{"stake": stakeAccount})},
+		{name: "withdraw stake", ops: single(stypes.Stake__WithdrawStake, owner, map[string]interface{}{"stake": stakeAccount, "withdrawDestination": receiver, "lamports": 1000})},
+		{name: "merge stake", ops: single(stypes.Stake__Merge, owner, map[string]interface{}{"stake": stakeAccount, "mergeDestination": newStake})},
+		{name: "split stake", ops: single(stypes.Stake__Split, owner, map[string]interface{}{"stake": stakeAccount, "splitDestination": newStake, "lamports": 1000})},
+		{name: "split stake with seed", ops: single(stypes.Stake__SplitWithSeed, owner, map[string]interface{}{"stake": stakeAccount, "splitSeed": "seed", "lamports": 1000, "rentExemptLamports": 2282880})},
+		{name: "authorize", ops: single(stypes.Stake__Authorize, owner, map[string]interface{}{"stake": stakeAccount, "newAuthority": newAuthority, "stakeAuthorizationType": 1})},
+		{name: "authorize checked", ops: single(stypes.Stake__AuthorizeChecked, owner, map[string]interface{}{"stake": stakeAccount, "newAuthority": newAuthority, "lockupCustodian": custodian})},
+		{name: "authorize with seed", ops: single(stypes.Stake__AuthorizeWithSeed, owner, map[string]interface{}{"stake": stakeAccount, "newAuthority": newAuthority, "authoritySeed": "seed"})},
+		{name: "set lockup", ops: single(stypes.Stake__SetLockup, owner, map[string]interface{}{"stake": stakeAccount, "lockupEpoch": 5, "lockupCustodian": custodian})},
+		{name: "clear lockup", ops: single(stypes.Stake__SetLockup, owner, map[string]interface{}{"stake": stakeAccount, "lockupEpoch": 0})},
+		{name: "set lockup checked", ops: single(stypes.Stake__SetLockupChecked, owner, map[string]interface{}{"stake": stakeAccount, "lockupUnixTimestamp": 1, "lockupCustodian": custodian})},
+		{name: "initialize checked", ops: single(stypes.Stake__InitializeChecked, owner, map[string]interface{}{"stake": stakeAccount, "staker": newAuthority})},
+		{name: "redelegate", ops: single(stypes.Stake__Redelegate, owner, map[string]interface{}{"stake": stakeAccount, "redelegateDestination": newStake, "voteAccount": vote})},
+	}
+
+	// parse needs nothing but the transaction
+	service := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	intentParser := parser.New(nil, nil, nil)
+	for _, test := range tests {
+		metadata := map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"}
+		if test.nonce {
+			metadata["nonce"] = nonce
+		}
+		for k, v := range test.metadata {
+			metadata[k] = v
+		}
+		payloads, rErr := service.ConstructionPayloads(context.Background(), &types.ConstructionPayloadsRequest{
+			Operations: test.ops,
+			Metadata:   metadata,
+		})
+		assert.Assert(t, rErr == nil, "%s: %v", test.name, rErr)
+		res, rErr := service.ConstructionParse(context.Background(), &types.ConstructionParseRequest{
+			Transaction: payloads.UnsignedTransaction,
+		})
+		assert.Assert(t, rErr == nil, test.name)
+		expected := test.ops
+		if test.parsed != nil {
+			expected = test.parsed
+		}
+		assert.NilError(t, intentParser.ExpectedOperations(expected, res.Operations, true, false), test.name)
+		assert.Equal(t, len(expected), len(res.Operations), test.name)
+		for k, op := range res.Operations {
+			assert.Check(t, is.DeepEqual(expected[k].Amount, op.Amount), test.name)
+			if len(expected[k].RelatedOperations) > 0 {
+				assert.Check(t, is.DeepEqual(expected[k].RelatedOperations, op.RelatedOperations), test.name)
+			}
+			assert.Check(t, is.DeepEqual(jsonValue(t, expected[k].Metadata), jsonValue(t, op.Metadata)), test.name)
+		}
+		assert.Check(t, is.DeepEqual(jsonValue(t, test.metadata), jsonValue(t, res.Metadata)), test.name)
+	}
+}
+
+func TestToInstructionsValidation(t *testing.T) {
+	owner := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	receiver := "42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v"
+	stakeAccount := "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"
+	sol := &types.Currency{Symbol: stypes.Symbol, Decimals: stypes.Decimals}
+	token := &types.Currency{Symbol: stakeAccount, Decimals: 2}
+	op := func(index int64, opType string, address string, value string, currency *types.Currency, metadata map[string]interface{}) *types.Operation {
+		o := &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index},
+			Type:                opType,
+			Metadata:            metadata,
+		}
+		if address != "" {
+			o.Account = &types.AccountIdentifier{Address: address}
+		}
+		if value != "" {
+			o.Amount = &types.Amount{Value: value, Currency: currency}
+		}
+		return o
+	}
+
+	tests := []struct {
+		name  string
+		ops   []*types.Operation
+		index int64
+		field string
+	}{
+		{"invalid account", []*types.Operation{op(0, stypes.System__Assign, "not-a-key", "", nil, nil)}, 0, "account.address"},
+		{"missing account", []*types.Operation{op(0, stypes.System__Assign, "", "", nil, nil)}, 0, "account"},
+		{"unsupported type", []*types.Operation{op(0, stypes.SplToken__Approve, owner, "", nil, nil)}, 0, "type"},
+		{"unknown program", []*types.Operation{op(0, "Vote__Withdraw", owner, "", nil, nil)}, 0, "type"},
+		{"missing currency", []*types.Operation{op(0, stypes.System__Transfer, owner, "-1", nil, nil)}, 0, "amount.currency"},
+		{"invalid value", []*types.Operation{op(0, stypes.System__Transfer, owner, "-1.5", sol, nil)}, 0, "amount.value"},
+		{"value too large", []*types.Operation{op(0, stypes.System__Transfer, owner, "-18446744073709551616", sol, nil)}, 0, "amount.value"},
+		{"token currency of system transfer", []*types.Operation{op(0, stypes.System__Transfer, owner, "-1", &types.Currency{Symbol: stakeAccount, Decimals: 2}, nil)}, 0, "amount.currency"},
+		{"invalid mint", []*types.Operation{op(0, stypes.SplToken__TransferChecked, owner, "-1", sol, nil)}, 0, "amount.currency.symbol"},
+		{"unpaired amount", []*types.Operation{
+			op(0, stypes.System__Transfer, owner, "-1", sol, nil),
+			op(1, stypes.System__Transfer, receiver, "2", sol, nil),
+		}, 0, "amount"},
+		{"missing destination", []*types.Operation{op(0, stypes.System__Transfer, owner, "", nil, map[string]interface{}{"lamports": 1})}, 0, "metadata.destination"},
+		{"metadata of wrong type", []*types.Operation{op(0, stypes.System__Allocate, owner, "", nil, map[string]interface{}{"space": "ten"})}, 0, "metadata.space"},
+		{"invalid metadata address", []*types.Operation{
+			op(0, stypes.Memo__Memo, "", "", nil, map[string]interface{}{"memo": "m"}),
+			op(1, stypes.Stake__DeactivateStake, owner, "", nil, map[string]interface{}{"stake": "not-a-key"}),
+		}, 1, "metadata.stake"},
+		{"missing stake", []*types.Operation{op(3, stypes.Stake__DelegateStake, owner, "", nil, map[string]interface{}{"voteAccount": receiver})}, 3, "metadata.stake"},
+		{"missing vote account", []*types.Operation{op(0, stypes.Stake__DelegateStake, owner, "", nil, map[string]interface{}{"stake": stakeAccount})}, 0, "metadata.voteAccount"},
+		{"invalid memo signer", []*types.Operation{op(0, stypes.Memo__Memo, owner, "", nil, map[string]interface{}{"memo": "m", "signers": []string{"x"}})}, 0, "metadata.signers[1]"},
+		{"missing program", []*types.Operation{op(0, stypes.Generic__Instruction, "", "", nil, map[string]interface{}{"data": "3Bxs4h24hBtQy9rw"})}, 0, "metadata.program_id"},
+		{"invalid generic account", []*types.Operation{op(0, stypes.Generic__Instruction, "", "", nil, map[string]interface{}{
+			"program_id": stakeAccount,
+			"accounts":   []map[string]interface{}{{"pubkey": owner}, {"pubkey": "x"}},
+		})}, 0, "metadata.accounts[1].pubkey"},
+		{"invalid generic data", []*types.Operation{op(0, stypes.Generic__Instruction, "", "", nil, map[string]interface{}{"program_id": stakeAccount, "data": "0OIl"})}, 0, "metadata.data"},
+		{"invalid generic encoding", []*types.Operation{op(0, stypes.Generic__Instruction, "", "", nil, map[string]interface{}{"program_id": stakeAccount, "encoding": "hex"})}, 0, "metadata.encoding"},
+		{"generic account not a signer", []*types.Operation{op(0, stypes.Generic__Instruction, owner, "", nil, map[string]interface{}{
+			"program_id": stakeAccount,
+			"accounts":   []map[string]interface{}{{"pubkey": owner, "is_writable": true}},
+		})}, 0, "account"},
+		{"generic amount", []*types.Operation{op(0, stypes.Generic__Instruction, owner, "-1", sol, map[string]interface{}{"program_id": stakeAccount})}, 0, "amount"},
+		{"missing wallet", []*types.Operation{op(0, stypes.SplAssociatedTokenAccount__Create, owner, "", nil, map[string]interface{}{"mint": stakeAccount})}, 0, "metadata.wallet"},
+		{"invalid multisig signer", []*types.Operation{op(0, stypes.SplToken__Burn, owner, "-1", token, map[string]interface{}{"signers": []string{"x"}})}, 0, "metadata.signers[0]"},
+		{"too many multisig signers", []*types.Operation{op(0, stypes.SplToken__Burn, owner, "-1", token, map[string]interface{}{"signers": []string{
+			owner, owner, owner, owner, owner, owner, owner, owner, owner, owner, owner, owner,
+		}})}, 0, "metadata.signers"},
+		{"multisig signers of wrap sol", []*types.Operation{
+			op(0, stypes.SplToken__WrapSol, owner, "-1", sol, map[string]interface{}{"signers": []string{receiver}}),
+			op(1, stypes.SplToken__WrapSol, receiver, "1", sol, nil),
+		}, 0, "metadata.signers"},
+		{"mint to a negative amount", []*types.Operation{op(0, stypes.SplToken__MintTo, owner, "-1", token, nil)}, 0, "amount.value"},
+		{"burn a positive amount", []*types.Operation{op(0, stypes.SplToken__Burn, owner, "1", token, nil)}, 0, "amount.value"},
+	}
+	for _, test := range tests {
+		_, _, err := ToInstructions(test.ops, ConstructionMetadata{})
+		assert.Assert(t, err != nil, test.name)
+		assert.Equal(t, ErrUnclearIntent.Code, err.Code, test.name)
+		assert.Equal(t, test.index, err.Details["operation_index"], test.name)
+		assert.Equal(t, test.field, err.Details["field"], test.name)
+	}
+}
+
+func TestPairOperations(t *testing.T) {
+	alice := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	bob := "42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v"
+	carol := "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"
+	dave := "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n"
+	sol := &types.Currency{Symbol: stypes.Symbol, Decimals: stypes.Decimals}
+	transfer := func(index int64, address string, value string, related ...int64) *types.Operation {
+		op := &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index},
+			Type:                stypes.System__Transfer,
+			Account:             &types.AccountIdentifier{Address: address},
+			Amount:              &types.Amount{Value: value, Currency: sol},
+		}
+		for _, r := range related {
+			op.RelatedOperations = append(op.RelatedOperations, &types.OperationIdentifier{Index: r})
+		}
+		return op
+	}
+	deactivate := func(index int64, stake string) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index},
+			Type:                stypes.Stake__DeactivateStake,
+			Account:             &types.AccountIdentifier{Address: alice},
+			Metadata:            map[string]interface{}{"stake": stake},
+		}
+	}
+
+	// each transfer is from, to
+	paid := []struct {
+		name      string
+		ops       []*types.Operation
+		transfers [][2]string
+	}{
+		{"adjacent legs of equal amounts", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, bob, "5"), transfer(2, alice, "-5"), transfer(3, carol, "5"),
+		}, [][2]string{{alice, bob}, {alice, carol}}},
+		{"equal amounts from one account", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, alice, "-5"), transfer(2, bob, "5"), transfer(3, carol, "5"),
+		}, [][2]string{{alice, bob}, {alice, carol}}},
+		{"equal amounts to one account", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, bob, "5"), transfer(2, dave, "-5"), transfer(3, bob, "5"),
+		}, [][2]string{{alice, bob}, {dave, bob}}},
+		{"related operations", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, dave, "-5"), transfer(2, bob, "5", 1), transfer(3, carol, "5", 0),
+		}, [][2]string{{alice, carol}, {dave, bob}}},
+		{"related destination first", []*types.Operation{
+			transfer(0, bob, "5", 1), transfer(1, alice, "-5"),
+		}, [][2]string{{alice, bob}}},
+		{"destination first", []*types.Operation{
+			transfer(0, bob, "5"), transfer(1, alice, "-5"),
+		}, [][2]string{{alice, bob}}},
+		{"related batch destination first", []*types.Operation{
+			transfer(0, bob, "5", 1), transfer(1, alice, "-10"), transfer(2, carol, "5", 1),
+		}, [][2]string{{alice, bob}, {alice, carol}}},
+		{"batch destination first", []*types.Operation{
+			transfer(0, bob, "5"), transfer(1, alice, "-15"), transfer(2, carol, "10"),
+		}, [][2]string{{alice, bob}, {alice, carol}}},
+		{"related batch", []*types.Operation{
+			transfer(0, alice, "-10"), transfer(1, bob, "5", 0), transfer(2, carol, "5", 0),
+		}, [][2]string{{alice, bob}, {alice, carol}}},
+		{"batch", []*types.Operation{
+			transfer(0, alice, "-15"), transfer(1, bob, "5"), transfer(2, carol, "10"),
+		}, [][2]string{{alice, bob}, {alice, carol}}},
+		{"batch and pair", []*types.Operation{
+			transfer(0, dave, "-7"), transfer(1, alice, "-15"), transfer(2, bob, "5"), transfer(3, carol, "10"), transfer(4, bob, "7"),
+		}, [][2]string{{dave, bob}, {alice, bob}, {alice, carol}}},
+	}
+	for _, test := range paid {
+		_, instructions, err := ToInstructions(test.ops, ConstructionMetadata{})
+		assert.Assert(t, err == nil, "%s: %v", test.name, err)
+		assert.Equal(t, len(test.transfers), len(instructions), test.name)
+		for k, in := range instructions {
+			assert.Equal(t, test.transfers[k][0], in.Accounts[0].PubKey.ToBase58(), test.name)
+			assert.Equal(t, test.transfers[k][1], in.Accounts[1].PubKey.ToBase58(), test.name)
+		}
+	}
+
+	// operations without an amount are never paired
+	_, instructions, err := ToInstructions([]*types.Operation{deactivate(0, bob), deactivate(1, carol)}, ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	assert.Equal(t, 2, len(instructions))
+	assert.Equal(t, bob, instructions[0].Accounts[0].PubKey.ToBase58())
+	assert.Equal(t, carol, instructions[1].Accounts[0].PubKey.ToBase58())
+
+	rejected := []struct {
+		name  string
+		ops   []*types.Operation
+		index int64
+		field string
+	}{
+		{"ambiguous", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, dave, "-5"), transfer(2, bob, "5"), transfer(3, carol, "5"),
+		}, 0, "amount"},
+		{"ambiguous adjacent legs", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, bob, "5"), transfer(2, dave, "-5"), transfer(3, carol, "5"),
+		}, 0, "amount"},
+		{"ambiguous leg left over", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, bob, "5"), transfer(2, carol, "5"),
+		}, 0, "amount"},
+		{"unknown related operation", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, bob, "5", 7),
+		}, 1, "related_operations"},
+		{"two sources of a destination", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, dave, "-5"), transfer(2, bob, "10", 0, 1),
+		}, 2, "amount.value"},
+		{"batch does not add up", []*types.Operation{
+			transfer(0, alice, "-10"), transfer(1, bob, "5", 0), transfer(2, carol, "6", 0),
+		}, 0, "amount.value"},
+		{"related amounts do not offset", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(1, bob, "6", 0),
+		}, 0, "amount.value"},
+		{"related operation without amount", []*types.Operation{
+			deactivate(0, bob), {
+				OperationIdentifier: &types.OperationIdentifier{Index: 1},
+				RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+				Type:                stypes.Stake__DeactivateStake,
+				Account:             &types.AccountIdentifier{Address: alice},
+				Metadata:            map[string]interface{}{"stake": carol},
+			},
+		}, 0, "amount"},
+		{"duplicate index", []*types.Operation{
+			transfer(0, alice, "-5"), transfer(0, bob, "5"),
+		}, 0, "operation_identifier.index"},
+	}
+	for _, test := range rejected {
+		_, _, err := ToInstructions(test.ops, ConstructionMetadata{})
+		assert.Assert(t, err != nil, test.name)
+		assert.Equal(t, ErrUnclearIntent.Code, err.Code, test.name)
+		assert.Equal(t, test.index, err.Details["operation_index"], test.name)
+		assert.Equal(t, test.field, err.Details["field"], test.name)
+	}
+}
+
+func TestBatchPayouts(t *testing.T) {
+	payer := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	mint := "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr"
+	sol := &types.Currency{Symbol: stypes.Symbol, Decimals: stypes.Decimals}
+	token := &types.Currency{Symbol: mint, Decimals: 2}
+	recipient := func(i int) string {
+		b := make([]byte, common.PublicKeyLength)
+		b[0], b[1] = 1, byte(i)
+		return common.PublicKeyFromBytes(b).ToBase58()
+	}
+	// one debit of n times 10 and n credits of 10
+	payout := func(opType string, currency *types.Currency, n int) []*types.Operation {
+		ops := []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                opType,
+			Account:             &types.AccountIdentifier{Address: payer},
+			Amount:              &types.Amount{Value: fmt.Sprint(-10 * n), Currency: currency},
+		}}
+		for i := 1; i <= n; i++ {
+			ops = append(ops, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: int64(i)},
+				Type:                opType,
+				Account:             &types.AccountIdentifier{Address: recipient(i)},
+				Amount:              &types.Amount{Value: "10", Currency: currency},
+			})
+		}
+		return ops
+	}
+	count := func(instructions []solPTypes.Instruction, programID common.PublicKey) int {
+		n := 0
+		for _, in := range instructions {
+			if in.ProgramID == programID {
+				n++
+			}
+		}
+		return n
+	}
+
+	// the priority fee is set once for all transfers
+	_, instructions, err := ToInstructions(payout(stypes.System__Transfer, sol, 3), ConstructionMetadata{
+		PriorityFee: stypes.PriorityFee{MicroLamports: "100"},
+	})
+	assert.Assert(t, err == nil)
+	assert.Equal(t, 4, len(instructions))
+	assert.Equal(t, 1, count(instructions, common.ComputeBudgetProgramID))
+	for i, in := range instructions[1:] {
+		assert.Equal(t, payer, in.Accounts[0].PubKey.ToBase58())
+		assert.Equal(t, recipient(i+1), in.Accounts[1].PubKey.ToBase58())
+	}
+
+	// the associated token account of the payer is created once
+	_, instructions, err = ToInstructions(payout(stypes.SplToken__TransferWithSystem, token, 3), ConstructionMetadata{})
+	assert.Assert(t, err == nil)
+	assert.Equal(t, 4, count(instructions, common.SPLAssociatedTokenAccountProgramID))
+	assert.Equal(t, 3, count(instructions, common.TokenProgramID))
+
+	service := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	preprocess := func(ops []*types.Operation) *types.Error {
+		_, err := service.ConstructionPreprocess(context.Background(), &types.ConstructionPreprocessRequest{
+			Operations: ops,
+			Metadata:   map[string]interface{}{},
+		})
+		return err
+	}
+	_, err = service.ConstructionPayloads(context.Background(), &types.ConstructionPayloadsRequest{
+		Operations: payout(stypes.System__Transfer, sol, 20),
+		Metadata:   map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"},
+	})
+	assert.Assert(t, err == nil)
+
+	for _, opType := range []string{stypes.System__Transfer, stypes.SplToken__TransferWithSystem} {
+		currency := sol
+		if opType == stypes.SplToken__TransferWithSystem {
+			currency = token
+		}
+		err = preprocess(payout(opType, currency, 40))
+		assert.Assert(t, err != nil, opType)
+		assert.Equal(t, ErrTransactionTooLarge.Code, err.Code, opType)
+		assert.Equal(t, 40, err.Details["transfers"], opType)
+		fit := err.Details["max_transfers"].(int)
+		assert.Assert(t, fit > 0 && fit < 40, opType)
+		assert.Assert(t, preprocess(payout(opType, currency, fit)) == nil, opType)
+		err = preprocess(payout(opType, currency, fit+1))
+		assert.Assert(t, err != nil, opType)
+		assert.Equal(t, fit, err.Details["max_transfers"], opType)
+	}
+}
+
+// blockedTransport fails every request, so that tests notice any use of
+// the network.
+type blockedTransport struct {
+	t *testing.T
+}
+
+func (b blockedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.t.Errorf("request to %s offline", req.URL)
+	return nil, errors.New("network is blocked")
+}
+
+func TestOfflineConstruction(t *testing.T) {
+	transport := http.DefaultTransport
+	http.DefaultTransport = blockedTransport{t}
+	defer func() { http.DefaultTransport = transport }()
+
+	ctx := context.Background()
+	service := NewConstructionAPIService(&configuration.Configuration{
+		Mode:    configuration.Offline,
+		GethURL: "http://127.0.0.1:8899",
+	}, nil)
+	key := ed25519.NewKeyFromSeed(append([]byte{3}, make([]byte, 31)...))
+	receiver := "42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v"
+	nonceAccount := "CZDpZ7KeMansnszdEGZ55C4HjGsMSQBzxPu6jqRm6ZrU"
+	sol := &types.Currency{Symbol: stypes.Symbol, Decimals: stypes.Decimals}
+
+	derived, rErr := service.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+		PublicKey: &types.PublicKey{Bytes: key.Public().(ed25519.PublicKey), CurveType: types.Edwards25519},
+	})
+	assert.Assert(t, rErr == nil)
+	sender := derived.AccountIdentifier.Address
+
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                stypes.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: sender},
+		Amount:              &types.Amount{Value: "-1000", Currency: sol},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                stypes.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: receiver},
+		Amount:              &types.Amount{Value: "1000", Currency: sol},
+	}}
+	withNonce := stypes.WithNonce{Account: nonceAccount, Authority: sender}
+	priorityFee := stypes.PriorityFee{MicroLamports: "100"}
+
+	// the authority of a nonce account cannot be fetched offline
+	_, rErr = service.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{stypes.WithNonceKey: stypes.WithNonce{Account: nonceAccount}},
+	})
+	assert.Equal(t, ErrUnavailableOffline.Code, rErr.Code)
+
+	preprocessed, rErr := service.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: ops,
+		Metadata: map[string]interface{}{
+			stypes.WithNonceKey:   withNonce,
+			stypes.PriorityFeeKey: priorityFee,
+		},
+	})
+	assert.Assert(t, rErr == nil)
+	assert.DeepEqual(t, withNonce, preprocessed.Options[stypes.WithNonceKey])
+
+	_, rErr = service.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{Options: preprocessed.Options})
+	assert.Equal(t, ErrUnavailableOffline.Code, rErr.Code)
+
+	// the metadata an online server returned for the options
+	metadata, err := marshalJSONMap(ConstructionMetadata{
+		BlockHash:   "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+		WithNonce:   withNonce,
+		PriorityFee: priorityFee,
+	})
+	assert.NilError(t, err)
+	payloads, rErr := service.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: ops,
+		Metadata:   metadata,
+	})
+	assert.Assert(t, rErr == nil)
+	assert.Equal(t, 1, len(payloads.Payloads))
+	assert.Equal(t, sender, payloads.Payloads[0].AccountIdentifier.Address)
+
+	intentParser := parser.New(nil, nil, nil)
+	parsed, rErr := service.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payloads.UnsignedTransaction,
+	})
+	assert.Assert(t, rErr == nil)
+	assert.NilError(t, intentParser.ExpectedOperations(ops, parsed.Operations, true, false))
+	assert.DeepEqual(t, withNonce, parsed.Metadata[stypes.WithNonceKey])
+
+	combined, rErr := service.ConstructionCombine(ctx, &types.ConstructionCombineRequest{
+		UnsignedTransaction: payloads.UnsignedTransaction,
+		Signatures: []*types.Signature{{
+			SigningPayload: payloads.Payloads[0],
+			PublicKey:      &types.PublicKey{Bytes: key.Public().(ed25519.PublicKey), CurveType: types.Edwards25519},
+			SignatureType:  types.Ed25519,
+			Bytes:          ed25519.Sign(key, payloads.Payloads[0].Bytes),
+		}},
+	})
+	assert.Assert(t, rErr == nil)
+
+	parsed, rErr = service.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Signed:      true,
+		Transaction: combined.SignedTransaction,
+	})
+	assert.Assert(t, rErr == nil)
+	assert.NilError(t, intentParser.ExpectedOperations(ops, parsed.Operations, true, false))
+	assert.DeepEqual(t, []*types.AccountIdentifier{{Address: sender}}, parsed.AccountIdentifierSigners)
+
+	hash, rErr := service.ConstructionHash(ctx, &types.ConstructionHashRequest{
+		SignedTransaction: combined.SignedTransaction,
+	})
+	assert.Assert(t, rErr == nil)
+	signed, err := solanago.GetTxFromStr(combined.SignedTransaction)
+	assert.NilError(t, err)
+	assert.Equal(t, base58.Encode(signed.Signatures[0]), hash.TransactionIdentifier.Hash)
+
+	_, rErr = service.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{
+		SignedTransaction: combined.SignedTransaction,
+	})
+	assert.Equal(t, ErrUnavailableOffline.Code, rErr.Code)
+}
+
+func TestConstructionDerive(t *testing.T) {
+	service := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	key := ed25519.NewKeyFromSeed(append([]byte{4}, make([]byte, 31)...)).Public().(ed25519.PublicKey)
+	wallet := common.PublicKeyFromBytes(key)
+	mint := "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr"
+	program := "9QU2QSxhb24FUX3Tu2FpczXjpK3VYrvRudywSZaM29mF"
+	derive := func(publicKey *types.PublicKey, metadata map[string]interface{}) (*types.ConstructionDeriveResponse, *types.Error) {
+		return service.ConstructionDerive(context.Background(), &types.ConstructionDeriveRequest{
+			PublicKey: publicKey,
+			Metadata:  metadata,
+		})
+	}
+	edwards := &types.PublicKey{Bytes: key, CurveType: types.Edwards25519}
+
+	res, rErr := derive(edwards, nil)
+	assert.Assert(t, rErr == nil)
+	assert.DeepEqual(t, &types.AccountIdentifier{Address: wallet.ToBase58()}, res.AccountIdentifier)
+
+	res, rErr = derive(edwards, map[string]interface{}{"mint": mint})
+	assert.Assert(t, rErr == nil)
+	tokenAccount, _, err := common.FindAssociatedTokenAddress(wallet, common.PublicKeyFromString(mint))
+	assert.NilError(t, err)
+	assert.Equal(t, wallet.ToBase58(), res.AccountIdentifier.Address)
+	assert.Equal(t, tokenAccount.ToBase58(), res.AccountIdentifier.SubAccount.Address)
+
+	res, rErr = derive(edwards, map[string]interface{}{
+		"program_id": program,
+		"seeds":      []map[string]string{{"utf8": "vault"}, {"base58": wallet.ToBase58()}, {"hex": "0102"}},
+	})
+	assert.Assert(t, rErr == nil)
+	address, bump, err := common.FindProgramAddress([][]byte{[]byte("vault"), wallet.Bytes(), {1, 2}}, common.PublicKeyFromString(program))
+	assert.NilError(t, err)
+	assert.Equal(t, address.ToBase58(), res.AccountIdentifier.Address)
+	assert.Equal(t, bump, res.AccountIdentifier.Metadata["bump"])
+
+	rejectedKeys := []*types.PublicKey{
+		nil,
+		{Bytes: key, CurveType: types.Secp256k1},
+		{Bytes: append([]byte{2}, key...), CurveType: types.Edwards25519},
+		{Bytes: key[:31], CurveType: types.Edwards25519},
+		// program derived addresses are off the curve
+		{Bytes: address.Bytes(), CurveType: types.Edwards25519},
+	}
+	for _, publicKey := range rejectedKeys {
+		_, rErr = derive(publicKey, nil)
+		assert.Equal(t, ErrUnableToDecompressPubkey.Code, rErr.Code)
+	}
+
+	var tooMany []map[string]string
+	for i := 0; i < common.MaxSeed; i++ {
+		tooMany = append(tooMany, map[string]string{"utf8": "seed"})
+	}
+	rejectedMetadata := []map[string]interface{}{
+		{"mint": "not-a-key"},
+		{"mint": mint, "program_id": program},
+		{"program_id": "not-a-key"},
+		{"seeds": []map[string]string{{"utf8": "vault"}}},
+		{"program_id": program, "seeds": []map[string]string{{"utf8": "vault", "hex": "01"}}},
+		{"program_id": program, "seeds": []map[string]string{{"hex": "0g"}}},
+		{"program_id": program, "seeds": []map[string]string{{"utf8": strings.Repeat("a", 33)}}},
+		{"program_id": program, "seeds": tooMany},
+		{"mint": 1},
+	}
+	for _, metadata := range rejectedMetadata {
+		_, rErr = derive(edwards, metadata)
+		assert.Assert(t, rErr != nil, "%v", metadata)
+		assert.Equal(t, ErrDeriveMetadataInvalid.Code, rErr.Code, "%v", metadata)
+	}
+}
+
+func TestMultisigTokenAuthority(t *testing.T) {
+	multisig := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	signers := []string{"42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v", "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n"}
+	fromToken := "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV"
+	toToken := "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L"
+	mint := "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr"
+	token := &types.Currency{Symbol: mint, Decimals: 2}
+	op := func(index int64, opType string, account *types.AccountIdentifier, value string, metadata map[string]interface{}) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index},
+			Type:                opType,
+			Account:             account,
+			Amount:              &types.Amount{Value: value, Currency: token},
+			Metadata:            metadata,
+		}
+	}
+	withSigners := map[string]interface{}{"signers": signers}
+
+	tests := []struct {
+		name      string
+		ops       []*types.Operation
+		authority int
+		signers   []string
+	}{
+		{"transfer checked", []*types.Operation{
+			op(0, stypes.SplToken__TransferChecked, solanago.TokenAccountIdentifier(multisig, fromToken), "-1", withSigners),
+			op(1, stypes.SplToken__TransferChecked, &types.AccountIdentifier{Address: toToken}, "1", nil),
+		}, 3, signers},
+		{"transfer", []*types.Operation{
+			op(0, stypes.SplToken__Transfer, solanago.TokenAccountIdentifier(multisig, fromToken), "-1", withSigners),
+			op(1, stypes.SplToken__Transfer, &types.AccountIdentifier{Address: toToken}, "1", nil),
+		}, 3, signers},
+		{"mint to", []*types.Operation{op(0, stypes.SplToken__MintTo, solanago.TokenAccountIdentifier(multisig, toToken), "1", withSigners)}, 2, signers},
+		{"burn", []*types.Operation{op(0, stypes.SplToken__Burn, solanago.TokenAccountIdentifier(multisig, fromToken), "-1", withSigners)}, 2, signers},
+		{"mint to by its authority", []*types.Operation{op(0, stypes.SplToken__MintTo, solanago.TokenAccountIdentifier(multisig, toToken), "1", nil)}, 2, []string{multisig}},
+		{"burn by its authority", []*types.Operation{op(0, stypes.SplToken__Burn, solanago.TokenAccountIdentifier(multisig, fromToken), "-1", nil)}, 2, []string{multisig}},
+	}
+
+	service := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	for _, test := range tests {
+		_, instructions, err := ToInstructions(test.ops, ConstructionMetadata{})
+		assert.Assert(t, err == nil, test.name)
+		assert.Equal(t, 1, len(instructions), test.name)
+		accounts := instructions[0].Accounts
+		assert.Equal(t, multisig, accounts[test.authority].PubKey.ToBase58(), test.name)
+		assert.Equal(t, len(test.signers) == 1, accounts[test.authority].IsSigner, test.name)
+		assert.DeepEqual(t, test.signers, GetUniqueSigners(instructions))
+
+		payloads, rErr := service.ConstructionPayloads(context.Background(), &types.ConstructionPayloadsRequest{
+			Operations: test.ops,
+			Metadata:   map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"},
+		})
+		assert.Assert(t, rErr == nil, test.name)
+		var payers []string
+		for _, payload := range payloads.Payloads {
+			payers = append(payers, payload.AccountIdentifier.Address)
+		}
+		assert.DeepEqual(t, test.signers, payers)
+	}
+}
+
+func TestFeePayer(t *testing.T) {
+	ctx := context.Background()
+	user := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	receiver := "42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v"
+	hotWallet := "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n"
+	fromToken := "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV"
+	toToken := "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L"
+	mint := "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr"
+	stakeAccount := "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"
+	vote := "9QU2QSxhb24FUX3Tu2FpczXjpK3VYrvRudywSZaM29mF"
+	sol := &types.Currency{Symbol: stypes.Symbol, Decimals: stypes.Decimals}
+	token := &types.Currency{Symbol: mint, Decimals: 2}
+	pair := func(opType string, from *types.AccountIdentifier, to *types.AccountIdentifier, value string, currency *types.Currency) []*types.Operation {
+		return []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                opType,
+			Account:             from,
+			Amount:              &types.Amount{Value: "-" + value, Currency: currency},
+		}, {
+			OperationIdentifier: &types.OperationIdentifier{Index: 1},
+			Type:                opType,
+			Account:             to,
+			Amount:              &types.Amount{Value: value, Currency: currency},
+		}}
+	}
+	single := func(opType string, metadata map[string]interface{}) []*types.Operation {
+		return []*types.Operation{{
+			OperationIdentifier: &types.OperationIdentifier{Index: 0},
+			Type:                opType,
+			Account:             &types.AccountIdentifier{Address: user},
+			Metadata:            metadata,
+		}}
+	}
+
+	tests := []struct {
+		name string
+		ops  []*types.Operation
+	}{
+		{"transfer", pair(stypes.System__Transfer, &types.AccountIdentifier{Address: user}, &types.AccountIdentifier{Address: receiver}, "1000", sol)},
+		{"token transfer checked", pair(stypes.SplToken__TransferChecked, solanago.TokenAccountIdentifier(user, fromToken), &types.AccountIdentifier{Address: toToken}, "1", token)},
+		{"token transfer with system", pair(stypes.SplToken__TransferWithSystem, &types.AccountIdentifier{Address: user}, &types.AccountIdentifier{Address: receiver}, "1", token)},
+		{"delegate stake", single(stypes.Stake__DelegateStake, map[string]interface{}{"stake": stakeAccount, "voteAccount": vote})},
+		{"withdraw stake", single(stypes.Stake__WithdrawStake, map[string]interface{}{"stake": stakeAccount, "withdrawDestination": receiver, "lamports": 1000, "feePayer": hotWallet})},
+		{"memo", single(stypes.Memo__Memo, map[string]interface{}{"memo": "deposit 1234"})},
+	}
+
+	service := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	for _, test := range tests {
+		preprocessed, rErr := service.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+			Operations: test.ops,
+			Metadata:   map[string]interface{}{stypes.FeePayerKey: hotWallet},
+		})
+		assert.Assert(t, rErr == nil, test.name)
+		assert.Equal(t, hotWallet, preprocessed.Options[stypes.FeePayerKey], test.name)
+		assert.Equal(t, "2", preprocessed.Options[stypes.FeeCalculationKey].(stypes.FeeCalculation).NumberOfSigners, test.name)
+
+		payloads, rErr := service.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+			Operations: test.ops,
+			Metadata: map[string]interface{}{
+				"blockhash":        "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA",
+				stypes.FeePayerKey: hotWallet,
+			},
+		})
+		assert.Assert(t, rErr == nil, test.name)
+		assert.Equal(t, 2, len(payloads.Payloads), test.name)
+		assert.Equal(t, hotWallet, payloads.Payloads[0].AccountIdentifier.Address, test.name)
+		assert.Equal(t, user, payloads.Payloads[1].AccountIdentifier.Address, test.name)
+		tx, err := solanago.GetTxFromStr(payloads.UnsignedTransaction)
+		assert.NilError(t, err)
+		assert.Equal(t, hotWallet, tx.Message.Accounts[0].ToBase58(), test.name)
+
+		parsed, rErr := service.ConstructionParse(ctx, &types.ConstructionParseRequest{
+			Transaction: payloads.UnsignedTransaction,
+		})
+		assert.Assert(t, rErr == nil, test.name)
+		assert.Equal(t, hotWallet, parsed.Metadata[stypes.FeePayerKey], test.name)
+	}
+
+	// the first signer pays without a fee payer
+	payloads, rErr := service.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: tests[0].ops,
+		Metadata:   map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"},
+	})
+	assert.Assert(t, rErr == nil)
+	assert.Equal(t, 1, len(payloads.Payloads))
+	parsed, rErr := service.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payloads.UnsignedTransaction,
+	})
+	assert.Assert(t, rErr == nil)
+	_, ok := parsed.Metadata[stypes.FeePayerKey]
+	assert.Assert(t, !ok)
+
+	_, rErr = service.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: tests[0].ops,
+		Metadata:   map[string]interface{}{stypes.FeePayerKey: "not-a-key"},
+	})
+	assert.Equal(t, ErrFeePayerInvalid.Code, rErr.Code)
+
+	_, rErr = service.ConstructionPreprocess(ctx, &types.ConstructionPreprocessRequest{
+		Operations: tests[4].ops,
+		Metadata:   map[string]interface{}{stypes.FeePayerKey: receiver},
+	})
+	assert.Equal(t, ErrUnclearIntent.Code, rErr.Code)
+	assert.Equal(t, "metadata.feePayer", rErr.Details["field"])
+}
+
+func TestGenericInstruction(t *testing.T) {
+	ctx := context.Background()
+	user := "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+	cosigner := "42jb8c6XpQ6KXxJEHSWPeoFvyrhuiGvcCJQKumdtW78v"
+	state := "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n"
+	program := "9QU2QSxhb24FUX3Tu2FpczXjpK3VYrvRudywSZaM29mF"
+	data := []byte{1, 2, 3, 4, 250}
+	accounts := []operations.GenericAccountMeta{
+		{Pubkey: user, IsSigner: true, IsWritable: true},
+		{Pubkey: state, IsWritable: true},
+		{Pubkey: cosigner, IsSigner: true},
+		{Pubkey: common.SysVarClockPubkey.ToBase58()},
+	}
+	sol := &types.Currency{Symbol: stypes.Symbol, Decimals: stypes.Decimals}
+	ops := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                stypes.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: user},
+		Amount:              &types.Amount{Value: "-1000", Currency: sol},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                stypes.System__Transfer,
+		Account:             &types.AccountIdentifier{Address: state},
+		Amount:              &types.Amount{Value: "1000", Currency: sol},
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 2},
+		Type:                stypes.Generic__Instruction,
+		Account:             &types.AccountIdentifier{Address: user},
+		Metadata: map[string]interface{}{
+			"program_id": program,
+			"accounts":   accounts,
+			"data":       base64.StdEncoding.EncodeToString(data),
+			"encoding":   "base64",
+		},
+	}}
+
+	_, instructions, rErr := ToInstructions(ops, ConstructionMetadata{})
+	assert.Assert(t, rErr == nil)
+	assert.Equal(t, 2, len(instructions))
+	assert.Equal(t, program, instructions[1].ProgramID.ToBase58())
+	assert.DeepEqual(t, data, instructions[1].Data)
+	for k, account := range accounts {
+		assert.Equal(t, account.Pubkey, instructions[1].Accounts[k].PubKey.ToBase58())
+		assert.Equal(t, account.IsSigner, instructions[1].Accounts[k].IsSigner)
+		assert.Equal(t, account.IsWritable, instructions[1].Accounts[k].IsWritable)
+	}
+	assert.DeepEqual(t, []string{user, cosigner}, GetUniqueSigners(instructions))
+
+	service := NewConstructionAPIService(&configuration.Configuration{Mode: configuration.Offline}, nil)
+	payloads, rErr := service.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: ops,
+		Metadata:   map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"},
+	})
+	assert.Assert(t, rErr == nil)
+	assert.Equal(t, 2, len(payloads.Payloads))
+
+	parsed, rErr := service.ConstructionParse(ctx, &types.ConstructionParseRequest{
+		Transaction: payloads.UnsignedTransaction,
+	})
+	assert.Assert(t, rErr == nil)
+	assert.NilError(t, parser.New(nil, nil, nil).ExpectedOperations(ops, parsed.Operations, true, false))
+	generic := parsed.Operations[2]
+	assert.Equal(t, program, generic.Metadata["program_id"])
+	assert.Equal(t, base58.Encode(data), generic.Metadata["data"])
+	assert.DeepEqual(t, accounts, generic.Metadata["accounts"])
+
+	// the recovered operation builds the same transaction
+	again, rErr := service.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+		Operations: parsed.Operations,
+		Metadata:   map[string]interface{}{"blockhash": "42gAeAs9JE1bzqjGQtprYcdi5KyZAQeDLYVoyVSpRLTA"},
+	})
+	assert.Assert(t, rErr == nil)
+	assert.Equal(t, payloads.UnsignedTransaction, again.UnsignedTransaction)
+}
@@ -15,7 +15,12 @@
 package services
 
 import (
+	"errors"
+
+	solPTypes "github.com/blocto/solana-go-sdk/types"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	"github.com/imerkle/rosetta-solana-go/solana/operations"
 )
 
 var (
@@ -35,6 +40,16 @@ var (
 		ErrCallMethodInvalid,
 		ErrInvalidAddress,
 		ErrGethNotReady,
+		ErrNonceAccountInvalid,
+		ErrTransactionNotFound,
+		ErrSubmitOptionsInvalid,
+		ErrSimulationFailed,
+		ErrInsufficientFunds,
+		ErrBlockhashNotFound,
+		ErrAccountInUse,
+		ErrTransactionTooLarge,
+		ErrDeriveMetadataInvalid,
+		ErrFeePayerInvalid,
 	}
 
 	// ErrUnimplemented is returned when an endpoint
@@ -132,6 +147,79 @@ var (
 		Message:   "node not ready",
 		Retriable: true,
 	}
+
+	// ErrNonceAccountInvalid is returned when a nonce
+	// account does not exist, is not initialized or is
+	// not controlled by the requested authority.
+	ErrNonceAccountInvalid = &types.Error{
+		Code:    14, //nolint
+		Message: "Invalid nonce account",
+	}
+
+	// ErrTransactionNotFound is returned when a transaction
+	// is not in the mempool of this server.
+	ErrTransactionNotFound = &types.Error{
+		Code:    15, //nolint
+		Message: "Transaction not found",
+	}
+
+	// ErrSubmitOptionsInvalid is returned when the metadata
+	// of a /construction/submit request is invalid.
+	ErrSubmitOptionsInvalid = &types.Error{
+		Code:    16, //nolint
+		Message: "Submit options invalid",
+	}
+
+	// ErrSimulationFailed is returned when a submitted transaction
+	// fails the preflight simulation of the node.
+	ErrSimulationFailed = &types.Error{
+		Code:    17, //nolint
+		Message: "Transaction simulation failed",
+	}
+
+	// ErrInsufficientFunds is returned when an account cannot pay
+	// for a submitted transaction.
+	ErrInsufficientFunds = &types.Error{
+		Code:    18, //nolint
+		Message: "Insufficient funds",
+	}
+
+	// ErrBlockhashNotFound is returned when the blockhash of a
+	// submitted transaction is unknown to the node or expired.
+	ErrBlockhashNotFound = &types.Error{
+		Code:      19, //nolint
+		Message:   "Blockhash not found",
+		Retriable: true,
+	}
+
+	// ErrAccountInUse is returned when an account of a submitted
+	// transaction is locked by another transaction.
+	ErrAccountInUse = &types.Error{
+		Code:      20, //nolint
+		Message:   "Account in use",
+		Retriable: true,
+	}
+
+	// ErrTransactionTooLarge is returned when the transaction of
+	// the operations is larger than the network accepts.
+	ErrTransactionTooLarge = &types.Error{
+		Code:    21, //nolint
+		Message: "Transaction too large",
+	}
+
+	// ErrDeriveMetadataInvalid is returned when the metadata
+	// of a /construction/derive request is invalid.
+	ErrDeriveMetadataInvalid = &types.Error{
+		Code:    22, //nolint
+		Message: "Derive metadata invalid",
+	}
+
+	// ErrFeePayerInvalid is returned when the fee_payer of a
+	// transaction is not a valid public key.
+	ErrFeePayerInvalid = &types.Error{
+		Code:    23, //nolint
+		Message: "Fee payer invalid",
+	}
 )
 
 // wrapErr adds details to the shared_types.Error provided. We use a function
@@ -151,3 +239,44 @@ func wrapErr(rErr *types.Error, err error) *types.Error {
 
 	return newErr
 }
+
+// wrapSubmitErr maps a sendTransaction error to the Rosetta error of its
+// simulation failure, with the program logs in the details.
+func wrapSubmitErr(err error, tx solPTypes.Transaction) *types.Error {
+	simErr := solanago.ParseSimulationError(err, tx)
+	if simErr == nil {
+		return wrapErr(ErrBroadcastFailed, err)
+	}
+
+	rErr := ErrSimulationFailed
+	switch {
+	case errors.Is(simErr, solanago.ErrInsufficientFunds):
+		rErr = ErrInsufficientFunds
+	case errors.Is(simErr, solanago.ErrBlockhashNotFound):
+		rErr = ErrBlockhashNotFound
+	case errors.Is(simErr, solanago.ErrAccountInUse):
+		rErr = ErrAccountInUse
+	}
+	newErr := wrapErr(rErr, simErr)
+	newErr.Details["error"] = simErr.Err
+	newErr.Details["logs"] = simErr.Logs
+	if simErr.InstructionIndex != nil {
+		newErr.Details["instruction_index"] = *simErr.InstructionIndex
+	}
+	if simErr.CustomCode != nil {
+		newErr.Details["custom_code"] = *simErr.CustomCode
+	}
+	return newErr
+}
+
+// operationErr reports the operation at index as ErrUnclearIntent, with
+// the invalid field in the details.
+func operationErr(index int64, err error) *types.Error {
+	newErr := wrapErr(ErrUnclearIntent, err)
+	newErr.Details["operation_index"] = index
+	var fieldErr *operations.FieldError
+	if errors.As(err, &fieldErr) {
+		newErr.Details["field"] = fieldErr.Field
+	}
+	return newErr
+}
@@ -0,0 +1,749 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/blocto/solana-go-sdk/common"
+	solPTypes "github.com/blocto/solana-go-sdk/types"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	"github.com/imerkle/rosetta-solana-go/solana/operations"
+	"github.com/imerkle/rosetta-solana-go/solana/parse/stake"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+	"github.com/mr-tron/base58"
+)
+
+// intentInstruction is a compiled instruction with its parsed info.
+type intentInstruction struct {
+	solPTypes.Instruction
+	parsed stypes.ParsedInstruction
+	kind   string
+	info   intentInfo
+}
+
+// intentInfo holds the fields of the parsed instructions that operations
+// are recovered from.
+type intentInfo struct {
+	stypes.ParsedInstructionMeta
+	Wallet          string
+	Base            string
+	Seed            string
+	SourceBase      string
+	SourceSeed      string
+	SourceOwner     string
+	NonceAccount    string
+	NonceAuthority  string
+	NewAuthorized   string
+	Memo            string
+	MicroLamports   uint64
+	VoteAccount     string
+	NewStakeAccount string
+	AuthorityType   string
+	AuthorityBase   string
+	AuthoritySeed   string
+	AuthorityOwner  string
+	Custodian       string
+	Staker          string
+	Withdrawer      string
+	Authorized      struct {
+		Staker     string
+		Withdrawer string
+	}
+	Lockup struct {
+		UnixTimestamp *int64
+		Epoch         *uint64
+		Custodian     string
+	}
+}
+
+func newIntentInstruction(ins solPTypes.Instruction, parsed stypes.ParsedInstruction) intentInstruction {
+	i := intentInstruction{Instruction: ins, parsed: parsed}
+	if parsed.Parsed != nil {
+		i.kind = parsed.Program + ":" + parsed.Parsed.InstructionType
+		j, _ := json.Marshal(parsed.Parsed.Info)
+		json.Unmarshal(j, &i.info)
+	}
+	return i
+}
+
+// multisigMetadata returns the metadata of a token instruction whose
+// authority is at index n, with the signers of a multisig authority.
+func (i intentInstruction) multisigMetadata(n int) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	if len(i.Accounts) > n+1 {
+		var signers []string
+		for _, account := range i.Accounts[n+1:] {
+			signers = append(signers, account.PubKey.ToBase58())
+		}
+		metadata["signers"] = signers
+	}
+	return metadata
+}
+
+func (i intentInstruction) account(n int) string {
+	if n >= len(i.Accounts) {
+		return ""
+	}
+	return i.Accounts[n].PubKey.ToBase58()
+}
+
+// intentRule recovers the operations building the instructions at the
+// start of ins, or returns nil if they do not have its shape.
+type intentRule func(ins []intentInstruction) []*types.Operation
+
+// intentRules are tried in order, so rules spanning more instructions
+// come before the ones matching a prefix of them.
+var intentRules = []intentRule{
+	wrapSolIntent,
+	redelegateIntent,
+	createStakeAndDelegateIntent,
+	transferWithSystemIntent,
+	createStakeAccountIntent,
+	splitStakeWithSeedIntent,
+	splTokenCreateAccountIntent,
+	createNonceAccountIntent,
+	transferNewIntent,
+	transferWithSystemDestinationIntent,
+	transferWithSystemSourceIntent,
+	systemIntent,
+	splTokenIntent,
+	associatedTokenAccountIntent,
+	memoIntent,
+	stakeIntent,
+	genericIntent,
+}
+
+// RecoverIntent returns the operations given to /construction/payloads
+// for the instructions of tx, in the form they are accepted there. A
+// nonce advance followed by other instructions is returned as with_nonce
+// metadata and compute unit prices of System and Stake operations as
+// priority_fee metadata.
+// A fee payer other than the first signer of the instructions is
+// returned as fee_payer metadata.
+// Operations are recovered from the transaction alone, so this works
+// offline: a withdrawal of the whole balance of a nonce account is
+// returned as System__WithdrawFromNonce, and the transferChecked built for
+// SplToken__Transfer as SplToken__TransferChecked.
+// Every recovered operation is checked to build its instructions again;
+// instructions no operation builds, such as token instructions that do not
+// name their mint, are returned as /block parses them.
+func RecoverIntent(tx solPTypes.Transaction, parsedTx stypes.ParsedTransaction) ([]*types.Operation, map[string]interface{}) {
+	var ins []intentInstruction
+	for k, in := range tx.Message.DecompileInstructions() {
+		ins = append(ins, newIntentInstruction(in, parsedTx.Message.Instructions[k]))
+	}
+
+	// a nonce advance on its own is an operation, with_nonce needs others
+	var metadata map[string]interface{}
+	if len(ins) > 1 && ins[0].kind == "system:advanceNonce" {
+		metadata = map[string]interface{}{
+			stypes.WithNonceKey: stypes.WithNonce{
+				Account:   ins[0].info.NonceAccount,
+				Authority: ins[0].info.NonceAuthority,
+			},
+		}
+		ins = ins[1:]
+	}
+
+	var instructions []solPTypes.Instruction
+	for _, in := range ins {
+		instructions = append(instructions, in.Instruction)
+	}
+	if signers := GetUniqueSigners(instructions); len(tx.Message.Accounts) > 0 && (len(signers) == 0 || signers[0] != tx.Message.Accounts[0].ToBase58()) {
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+		metadata[stypes.FeePayerKey] = tx.Message.Accounts[0].ToBase58()
+	}
+
+	var ops []*types.Operation
+	var priorityFee uint64
+	for len(ins) > 0 {
+		recovered, n, fee := recoverOperations(ins)
+		if n > 0 {
+			recovered, n = extendBatch(recovered, n, ins, fee)
+		}
+		if n == 0 {
+			tx := stypes.ParsedTransaction{Message: stypes.ParsedMessage{Instructions: []stypes.ParsedInstruction{ins[0].parsed}}}
+			recovered, n = solanago.GetRosOperationsFromTx(tx, ""), 1
+		}
+		if priorityFee == 0 {
+			priorityFee = fee
+		}
+		// related operations are indexed within recovered
+		for _, op := range recovered {
+			for _, related := range op.RelatedOperations {
+				related.Index += int64(len(ops))
+			}
+		}
+		ops = append(ops, recovered...)
+		ins = ins[n:]
+	}
+	for k, op := range ops {
+		op.OperationIdentifier = &types.OperationIdentifier{Index: int64(k)}
+	}
+
+	if priorityFee > 0 {
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+		metadata[stypes.PriorityFeeKey] = stypes.PriorityFee{MicroLamports: fmt.Sprint(priorityFee)}
+	}
+	return ops, metadata
+}
+
+// recoverOperations returns the operations building the instructions at
+// the start of ins, how many instructions they build and the compute unit
+// price they were built with.
+func recoverOperations(ins []intentInstruction) ([]*types.Operation, int, uint64) {
+	for _, rule := range intentRules {
+		if ops := rule(ins); ops != nil {
+			if n := verifyIntent(ops, ins, 0); n > 0 {
+				return ops, n, 0
+			}
+		}
+	}
+	if len(ins) > 1 && ins[0].kind == "compute-budget:setComputeUnitPrice" && ins[0].info.MicroLamports > 0 {
+		fee := ins[0].info.MicroLamports
+		for _, rule := range intentRules {
+			if ops := rule(ins[1:]); ops != nil {
+				if n := verifyIntent(ops, ins, fee); n > 0 {
+					return ops, n, fee
+				}
+			}
+		}
+	}
+	return nil, 0, 0
+}
+
+// extendBatch turns the transfer ops building the first n instructions of
+// ins into a batch payout of the transfers after them that its source
+// pays, as long as the batch builds the same instructions. It returns the
+// operations and the number of instructions they build.
+func extendBatch(ops []*types.Operation, n int, ins []intentInstruction, fee uint64) ([]*types.Operation, int) {
+	if len(ops) != 2 || !solanago.Contains(batchTypes, ops[0].Type) {
+		return ops, n
+	}
+	debit, credits := ops[0], ops[1:]
+	for n < len(ins) {
+		next, _, _ := recoverOperations(ins[n:])
+		if len(next) != 2 || next[1].Amount == nil || next[1].Amount.Currency.Symbol != debit.Amount.Currency.Symbol {
+			break
+		}
+		credit := &types.Operation{
+			Type:    debit.Type,
+			Account: next[1].Account,
+			Amount:  next[1].Amount,
+		}
+		batch := batchOperations(debit, append(credits[:len(credits):len(credits)], credit))
+		m := verifyIntent(batch, ins, fee)
+		if m <= n {
+			break
+		}
+		credits = batch[1:]
+		n = m
+	}
+	if len(credits) == 1 {
+		return ops, n
+	}
+	return batchOperations(debit, credits), n
+}
+
+// batchOperations returns the debit of the source of debit that pays
+// credits, which it relates to, followed by the credits.
+func batchOperations(debit *types.Operation, credits []*types.Operation) []*types.Operation {
+	total := new(big.Int)
+	batch := []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                debit.Type,
+		Account:             debit.Account,
+		Metadata:            debit.Metadata,
+	}}
+	for k, credit := range credits {
+		value, _ := new(big.Int).SetString(credit.Amount.Value, 10)
+		total.Add(total, value)
+		batch[0].RelatedOperations = append(batch[0].RelatedOperations, &types.OperationIdentifier{Index: int64(k + 1)})
+		batch = append(batch, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(k + 1)},
+			Type:                credit.Type,
+			Account:             credit.Account,
+			Amount:              credit.Amount,
+		})
+	}
+	batch[0].Amount = &types.Amount{Value: "-" + total.String(), Currency: debit.Amount.Currency}
+	return batch
+}
+
+// verifyIntent builds ops with the compute unit price fee and returns the
+// number of instructions built if they are the first ones of ins, 0
+// otherwise.
+func verifyIntent(ops []*types.Operation, ins []intentInstruction, fee uint64) int {
+	for _, op := range ops {
+		if op.Account == nil && op.Type != stypes.Memo__Memo && op.Type != stypes.Generic__Instruction {
+			return 0
+		}
+	}
+	var meta ConstructionMetadata
+	if fee > 0 {
+		meta.PriorityFee = stypes.PriorityFee{MicroLamports: fmt.Sprint(fee)}
+	}
+	_, built, err := ToInstructions(ops, meta)
+	if err != nil || len(built) == 0 || len(built) > len(ins) {
+		return 0
+	}
+	for k, in := range built {
+		if !sameInstruction(in, ins[k].Instruction) {
+			return 0
+		}
+	}
+	return len(built)
+}
+
+// sameInstruction compares the program, accounts and data of two
+// instructions. Signer and writable flags are left out as compiling the
+// message merges them across instructions.
+func sameInstruction(a solPTypes.Instruction, b solPTypes.Instruction) bool {
+	if a.ProgramID != b.ProgramID || len(a.Accounts) != len(b.Accounts) || !bytes.Equal(a.Data, b.Data) {
+		return false
+	}
+	for k := range a.Accounts {
+		if a.Accounts[k].PubKey != b.Accounts[k].PubKey {
+			return false
+		}
+	}
+	return true
+}
+
+func hasShape(ins []intentInstruction, kinds ...string) bool {
+	if len(ins) < len(kinds) {
+		return false
+	}
+	for k, kind := range kinds {
+		if ins[k].kind != kind {
+			return false
+		}
+	}
+	return true
+}
+
+func intentAccount(address string) *types.AccountIdentifier {
+	if address == "" {
+		return nil
+	}
+	return &types.AccountIdentifier{Address: address}
+}
+
+// intentTokenAccount keys a token account by its owner unless the owner
+// is the account itself.
+func intentTokenAccount(owner string, tokenAccount string) *types.AccountIdentifier {
+	if owner == tokenAccount {
+		return intentAccount(tokenAccount)
+	}
+	return &types.AccountIdentifier{
+		Address:    owner,
+		SubAccount: &types.SubAccountIdentifier{Address: tokenAccount},
+	}
+}
+
+func solCurrency() *types.Currency {
+	return &types.Currency{Symbol: stypes.Symbol, Decimals: stypes.Decimals}
+}
+
+func intentOp(opType string, account *types.AccountIdentifier, metadata map[string]interface{}) []*types.Operation {
+	return []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                opType,
+		Account:             account,
+		Metadata:            metadata,
+	}}
+}
+
+// intentPair is a balance-changing operation: the source op carrying the
+// metadata, then the destination op.
+func intentPair(opType string, from *types.AccountIdentifier, to *types.AccountIdentifier, value uint64, currency *types.Currency, metadata map[string]interface{}) []*types.Operation {
+	return []*types.Operation{{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                opType,
+		Account:             from,
+		Amount:              &types.Amount{Value: "-" + fmt.Sprint(value), Currency: currency},
+		Metadata:            metadata,
+	}, {
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                opType,
+		Account:             to,
+		Amount:              &types.Amount{Value: fmt.Sprint(value), Currency: currency},
+		RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+	}}
+}
+
+// setIf adds key to m unless value is its default.
+func setIf(m map[string]interface{}, key string, value interface{}, def interface{}) map[string]interface{} {
+	if value != def {
+		m[key] = value
+	}
+	return m
+}
+
+func wrapSolIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "spl-associated-token-account:create", "system:transfer", "spl-token:syncNative") || ins[0].info.Mint != stypes.NativeMint {
+		return nil
+	}
+	source := ins[1].info.Source
+	metadata := setIf(map[string]interface{}{}, "authority", ins[0].info.Source, source)
+	return intentPair(stypes.SplToken__WrapSol, intentAccount(source), intentAccount(ins[0].info.Wallet), ins[1].info.Lamports, solCurrency(), metadata)
+}
+
+func redelegateIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "system:allocate", "system:assign", "stake:redelegate") {
+		return nil
+	}
+	info := ins[2].info
+	return intentOp(stypes.Stake__Redelegate, intentAccount(info.StakeAuthority), map[string]interface{}{
+		"stake":                 info.StakeAccount,
+		"redelegateDestination": info.NewStakeAccount,
+		"voteAccount":           info.VoteAccount,
+	})
+}
+
+// stakeAccountMetadata describes the stake account created by the
+// system and stake instructions at the start of ins.
+func stakeAccountMetadata(ins []intentInstruction) map[string]interface{} {
+	source := ins[0].info.Source
+	initialize := ins[1].info
+	metadata := map[string]interface{}{
+		"stake":    initialize.StakeAccount,
+		"lamports": ins[0].info.Lamports,
+	}
+	setIf(metadata, "staker", initialize.Authorized.Staker, source)
+	setIf(metadata, "withdrawer", initialize.Authorized.Withdrawer, source)
+	if initialize.Lockup.UnixTimestamp != nil {
+		setIf(metadata, "lockupUnixTimestamp", *initialize.Lockup.UnixTimestamp, int64(0))
+	}
+	if initialize.Lockup.Epoch != nil {
+		setIf(metadata, "lockupEpoch", *initialize.Lockup.Epoch, uint64(0))
+	}
+	setIf(metadata, "lockupCustodian", initialize.Lockup.Custodian, common.PublicKey{}.ToBase58())
+	return metadata
+}
+
+func createStakeAndDelegateIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "system:createAccount", "stake:initialize", "stake:delegate") {
+		return nil
+	}
+	metadata := stakeAccountMetadata(ins)
+	metadata["voteAccount"] = ins[2].info.VoteAccount
+	return intentOp(stypes.Stake__CreateStakeAndDelegate, intentAccount(ins[0].info.Source), metadata)
+}
+
+func createStakeAccountIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "system:createAccount", "stake:initialize") {
+		return nil
+	}
+	return intentOp(stypes.Stake__CreateStakeAccount, intentAccount(ins[0].info.Source), stakeAccountMetadata(ins))
+}
+
+func splitStakeWithSeedIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "system:createAccountWithSeed", "stake:split") {
+		return nil
+	}
+	split := ins[1].info
+	return intentOp(stypes.Stake__SplitWithSeed, intentAccount(split.StakeAuthority), map[string]interface{}{
+		"stake":              split.StakeAccount,
+		"splitSeed":          ins[0].info.Seed,
+		"lamports":           split.Lamports,
+		"rentExemptLamports": ins[0].info.Lamports,
+	})
+}
+
+func splTokenCreateAccountIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "system:createAccount", "spl-token:initializeAccount") {
+		return nil
+	}
+	source := ins[0].info.Source
+	metadata := map[string]interface{}{
+		"destination": ins[0].info.NewAccount,
+		"mint":        ins[1].info.Mint,
+		"amount":      ins[0].info.Lamports,
+	}
+	setIf(metadata, "authority", ins[1].info.Owner, source)
+	return intentOp(stypes.SplToken__CreateAccount, intentAccount(source), metadata)
+}
+
+func createNonceAccountIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "system:createAccount", "system:initializeNonce") {
+		return nil
+	}
+	source := ins[0].info.Source
+	metadata := map[string]interface{}{
+		"destination": ins[0].info.NewAccount,
+		"lamports":    ins[0].info.Lamports,
+	}
+	setIf(metadata, "authority", ins[1].info.NonceAuthority, source)
+	return intentOp(stypes.System__CreateNonceAccount, intentAccount(source), metadata)
+}
+
+// tokenAmount returns the amount and currency of a transferChecked
+// instruction.
+func tokenAmount(in intentInstruction) (uint64, *types.Currency) {
+	var amount uint64
+	fmt.Sscan(in.info.TokenAmount.Amount, &amount)
+	return amount, &types.Currency{Symbol: in.info.Mint, Decimals: int32(in.info.TokenAmount.Decimals)}
+}
+
+// transferWithSystemIntent matches a transfer between the associated
+// token accounts of two system accounts that are both created if needed.
+func transferWithSystemIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "spl-associated-token-account:create", "spl-associated-token-account:create", "spl-token:transferChecked") {
+		return nil
+	}
+	source := ins[0].info.Wallet
+	amount, currency := tokenAmount(ins[2])
+	metadata := setIf(map[string]interface{}{}, "authority", ins[2].account(3), source)
+	return intentPair(stypes.SplToken__TransferWithSystem, intentAccount(source), intentAccount(ins[1].info.Wallet), amount, currency, metadata)
+}
+
+func transferNewIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "spl-associated-token-account:create", "spl-token:transferChecked") {
+		return nil
+	}
+	authority := ins[1].account(3)
+	amount, currency := tokenAmount(ins[1])
+	return intentPair(stypes.SplToken__TransferNew, intentTokenAccount(authority, ins[1].info.Source), intentAccount(ins[0].info.Wallet), amount, currency, map[string]interface{}{})
+}
+
+// transferWithSystemDestinationIntent matches a transfer from a given
+// token account that creates the destination token account if needed.
+func transferWithSystemDestinationIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "spl-associated-token-account:create", "spl-token:transferChecked") {
+		return nil
+	}
+	amount, currency := tokenAmount(ins[1])
+	metadata := map[string]interface{}{"source_token": ins[1].info.Source}
+	return intentPair(stypes.SplToken__TransferWithSystem, intentAccount(ins[1].account(3)), intentAccount(ins[0].info.Wallet), amount, currency, metadata)
+}
+
+// transferWithSystemSourceIntent matches a transfer to a given token
+// account that creates the source token account if needed.
+func transferWithSystemSourceIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "spl-associated-token-account:create", "spl-token:transferChecked") {
+		return nil
+	}
+	source := ins[0].info.Wallet
+	destination := ins[1].info.Destination
+	amount, currency := tokenAmount(ins[1])
+	metadata := setIf(map[string]interface{}{"destination_token": destination}, "authority", ins[1].account(3), source)
+	return intentPair(stypes.SplToken__TransferWithSystem, intentAccount(source), intentAccount(destination), amount, currency, metadata)
+}
+
+func systemIntent(ins []intentInstruction) []*types.Operation {
+	if len(ins) == 0 {
+		return nil
+	}
+	info := ins[0].info
+	switch ins[0].kind {
+	case "system:transfer":
+		return intentPair(stypes.System__Transfer, intentAccount(info.Source), intentAccount(info.Destination), info.Lamports, solCurrency(), map[string]interface{}{})
+	case "system:createAccount":
+		metadata := setIf(map[string]interface{}{"space": info.Space}, "owner", info.Owner, common.TokenProgramID.ToBase58())
+		return intentPair(stypes.System__CreateAccount, intentAccount(info.Source), intentAccount(info.NewAccount), info.Lamports, solCurrency(), metadata)
+	case "system:createAccountWithSeed":
+		metadata := map[string]interface{}{
+			"seed":  info.Seed,
+			"space": info.Space,
+		}
+		setIf(metadata, "owner", info.Owner, common.SystemProgramID.ToBase58())
+		setIf(metadata, "base", info.Base, info.Source)
+		return intentPair(stypes.System__CreateAccountWithSeed, intentAccount(info.Source), intentAccount(info.NewAccount), info.Lamports, solCurrency(), metadata)
+	case "system:transferWithSeed":
+		metadata := map[string]interface{}{
+			"base": info.SourceBase,
+			"seed": info.SourceSeed,
+		}
+		setIf(metadata, "owner", info.SourceOwner, common.SystemProgramID.ToBase58())
+		return intentPair(stypes.System__TransferWithSeed, intentAccount(info.Source), intentAccount(info.Destination), info.Lamports, solCurrency(), metadata)
+	case "system:withdrawFromNonce":
+		// the authority would default to the one on chain
+		metadata := map[string]interface{}{"authority": info.NonceAuthority}
+		return intentPair(stypes.System__WithdrawFromNonce, intentAccount(info.NonceAccount), intentAccount(info.Destination), info.Lamports, solCurrency(), metadata)
+	case "system:assign":
+		return intentOp(stypes.System__Assign, intentAccount(info.Account), setIf(map[string]interface{}{}, "owner", info.Owner, common.TokenProgramID.ToBase58()))
+	case "system:allocate":
+		return intentOp(stypes.System__Allocate, intentAccount(info.Account), map[string]interface{}{"space": info.Space})
+	case "system:allocateWithSeed":
+		metadata := map[string]interface{}{
+			"seed":  info.Seed,
+			"space": info.Space,
+		}
+		return intentOp(stypes.System__AllocateWithSeed, intentAccount(info.Base), setIf(metadata, "owner", info.Owner, common.SystemProgramID.ToBase58()))
+	case "system:assignWithSeed":
+		metadata := setIf(map[string]interface{}{"seed": info.Seed}, "owner", info.Owner, common.SystemProgramID.ToBase58())
+		return intentOp(stypes.System__AssignWithSeed, intentAccount(info.Base), metadata)
+	case "system:advanceNonce":
+		return intentOp(stypes.System__AdvanceNonce, intentAccount(info.NonceAuthority), map[string]interface{}{"nonce_account": info.NonceAccount})
+	case "system:authorizeNonce":
+		return intentOp(stypes.System__AuthorizeNonce, intentAccount(info.NonceAuthority), map[string]interface{}{
+			"nonce_account": info.NonceAccount,
+			"new_authority": info.NewAuthorized,
+		})
+	}
+	return nil
+}
+
+func splTokenIntent(ins []intentInstruction) []*types.Operation {
+	if len(ins) == 0 {
+		return nil
+	}
+	info := ins[0].info
+	switch ins[0].kind {
+	case "spl-token:transferChecked":
+		amount, currency := tokenAmount(ins[0])
+		return intentPair(stypes.SplToken__TransferChecked, intentTokenAccount(ins[0].account(3), info.Source), intentAccount(info.Destination), amount, currency, ins[0].multisigMetadata(3))
+	case "spl-token:mintToChecked", "spl-token:burnChecked":
+		amount, currency := tokenAmount(ins[0])
+		op := intentOp(stypes.SplToken__MintTo, intentTokenAccount(ins[0].account(2), info.Account), ins[0].multisigMetadata(2))
+		op[0].Amount = &types.Amount{Value: fmt.Sprint(amount), Currency: currency}
+		if ins[0].kind == "spl-token:burnChecked" {
+			op[0].Type = stypes.SplToken__Burn
+			op[0].Amount.Value = "-" + op[0].Amount.Value
+		}
+		return op
+	case "spl-token:closeAccount":
+		owner := ins[0].account(2)
+		metadata := map[string]interface{}{}
+		if wrapped, _, _ := common.FindAssociatedTokenAddress(p(owner), p(stypes.NativeMint)); wrapped.ToBase58() != info.Account {
+			metadata["source_token"] = info.Account
+		}
+		setIf(metadata, "destination", info.Destination, owner)
+		return intentOp(stypes.SplToken__UnwrapSol, intentAccount(owner), metadata)
+	}
+	return nil
+}
+
+func associatedTokenAccountIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "spl-associated-token-account:create") {
+		return nil
+	}
+	return intentOp(stypes.SplAssociatedTokenAccount__Create, intentAccount(ins[0].info.Source), map[string]interface{}{
+		"wallet": ins[0].info.Wallet,
+		"mint":   ins[0].info.Mint,
+	})
+}
+
+func memoIntent(ins []intentInstruction) []*types.Operation {
+	if !hasShape(ins, "spl-memo:memo") {
+		return nil
+	}
+	metadata := map[string]interface{}{"memo": ins[0].info.Memo}
+	var account *types.AccountIdentifier
+	if len(ins[0].Accounts) > 0 {
+		account = intentAccount(ins[0].account(0))
+		var signers []string
+		for n := 1; n < len(ins[0].Accounts); n++ {
+			signers = append(signers, ins[0].account(n))
+		}
+		if len(signers) > 0 {
+			metadata["signers"] = signers
+		}
+	}
+	return intentOp(stypes.Memo__Memo, account, metadata)
+}
+
+// genericIntent matches an instruction of a program that is not parsed.
+// The signer and writable flags are those of the compiled message, which
+// merges them across instructions.
+func genericIntent(ins []intentInstruction) []*types.Operation {
+	if len(ins) == 0 || ins[0].parsed.Parsed != nil {
+		return nil
+	}
+	var account *types.AccountIdentifier
+	var accounts []operations.GenericAccountMeta
+	for _, meta := range ins[0].Accounts {
+		address := meta.PubKey.ToBase58()
+		if meta.IsSigner && account == nil {
+			account = intentAccount(address)
+		}
+		accounts = append(accounts, operations.GenericAccountMeta{Pubkey: address, IsSigner: meta.IsSigner, IsWritable: meta.IsWritable})
+	}
+	metadata := map[string]interface{}{
+		"program_id": ins[0].ProgramID.ToBase58(),
+		"data":       base58.Encode(ins[0].Data),
+	}
+	if len(accounts) > 0 {
+		metadata["accounts"] = accounts
+	}
+	return intentOp(stypes.Generic__Instruction, account, metadata)
+}
+
+// stakeAuthorizationType is the index of the parsed authority type.
+func stakeAuthorizationType(authorityType string) uint32 {
+	for k, t := range stake.AuthorityTypes {
+		if t == authorityType {
+			return uint32(k)
+		}
+	}
+	return 0
+}
+
+func stakeIntent(ins []intentInstruction) []*types.Operation {
+	if len(ins) == 0 {
+		return nil
+	}
+	info := ins[0].info
+	metadata := map[string]interface{}{"stake": info.StakeAccount}
+	switch ins[0].kind {
+	case "stake:delegate":
+		metadata["voteAccount"] = info.VoteAccount
+		return intentOp(stypes.Stake__DelegateStake, intentAccount(info.StakeAuthority), metadata)
+	case "stake:deactivate":
+		return intentOp(stypes.Stake__DeactivateStake, intentAccount(info.StakeAuthority), metadata)
+	case "stake:withdraw":
+		metadata["withdrawDestination"] = info.Destination
+		metadata["lamports"] = info.Lamports
+		setIf(metadata, "lockupCustodian", info.Custodian, "")
+		return intentOp(stypes.Stake__WithdrawStake, intentAccount(info.WithdrawAuthority), metadata)
+	case "stake:merge":
+		metadata["stake"] = info.Source
+		metadata["mergeDestination"] = info.Destination
+		return intentOp(stypes.Stake__Merge, intentAccount(info.StakeAuthority), metadata)
+	case "stake:split":
+		metadata["splitDestination"] = info.NewSplitAccount
+		metadata["lamports"] = info.Lamports
+		return intentOp(stypes.Stake__Split, intentAccount(info.StakeAuthority), metadata)
+	case "stake:authorize", "stake:authorizeChecked":
+		opType := stypes.Stake__Authorize
+		if ins[0].kind == "stake:authorizeChecked" {
+			opType = stypes.Stake__AuthorizeChecked
+		}
+		metadata["newAuthority"] = info.NewAuthority
+		setIf(metadata, "stakeAuthorizationType", stakeAuthorizationType(info.AuthorityType), uint32(0))
+		setIf(metadata, "lockupCustodian", info.Custodian, "")
+		return intentOp(opType, intentAccount(info.Authority), metadata)
+	case "stake:authorizeWithSeed":
+		metadata["newAuthority"] = info.NewAuthorized
+		metadata["authoritySeed"] = info.AuthoritySeed
+		setIf(metadata, "authorityOwner", info.AuthorityOwner, common.SystemProgramID.ToBase58())
+		setIf(metadata, "stakeAuthorizationType", stakeAuthorizationType(info.AuthorityType), uint32(0))
+		setIf(metadata, "lockupCustodian", info.Custodian, "")
+		return intentOp(stypes.Stake__AuthorizeWithSeed, intentAccount(info.AuthorityBase), metadata)
+	case "stake:setLockup", "stake:setLockupChecked":
+		opType := stypes.Stake__SetLockup
+		if ins[0].kind == "stake:setLockupChecked" {
+			opType = stypes.Stake__SetLockupChecked
+		}
+		if info.Lockup.UnixTimestamp != nil {
+			metadata["lockupUnixTimestamp"] = *info.Lockup.UnixTimestamp
+		}
+		if info.Lockup.Epoch != nil {
+			metadata["lockupEpoch"] = *info.Lockup.Epoch
+		}
+		setIf(metadata, "lockupCustodian", info.Lockup.Custodian, "")
+		return intentOp(opType, intentAccount(info.Custodian), metadata)
+	case "stake:initializeChecked":
+		setIf(metadata, "staker", info.Staker, info.Withdrawer)
+		return intentOp(stypes.Stake__InitializeChecked, intentAccount(info.Withdrawer), metadata)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/imerkle/rosetta-solana-go/configuration"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// MempoolAPIService implements the server.MempoolAPIServicer interface
+// from the transactions submitted through this server.
+type MempoolAPIService struct {
+	config *configuration.Configuration
+	client *solanago.Client
+}
+
+// NewMempoolAPIService creates a new instance of a MempoolAPIService.
+func NewMempoolAPIService(
+	cfg *configuration.Configuration,
+	client *solanago.Client,
+) *MempoolAPIService {
+	return &MempoolAPIService{
+		config: cfg,
+		client: client,
+	}
+}
+
+// Mempool implements the /mempool endpoint.
+func (s *MempoolAPIService) Mempool(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.MempoolResponse, *types.Error) {
+	if s.config.Mode != configuration.Online {
+		return nil, ErrUnavailableOffline
+	}
+
+	// the rebroadcaster drops landed and expired transactions
+	identifiers := []*types.TransactionIdentifier{}
+	for _, tx := range s.client.Mempool.Transactions() {
+		identifiers = append(identifiers, &types.TransactionIdentifier{Hash: tx.Hash})
+	}
+	return &types.MempoolResponse{
+		TransactionIdentifiers: identifiers,
+	}, nil
+}
+
+// MempoolTransaction implements the /mempool/transaction endpoint.
+func (s *MempoolAPIService) MempoolTransaction(
+	ctx context.Context,
+	request *types.MempoolTransactionRequest,
+) (*types.MempoolTransactionResponse, *types.Error) {
+	if s.config.Mode != configuration.Online {
+		return nil, ErrUnavailableOffline
+	}
+
+	tx, ok := s.client.Mempool.Get(request.TransactionIdentifier.Hash)
+	if !ok {
+		return nil, wrapErr(ErrTransactionNotFound, fmt.Errorf("%s is not pending", request.TransactionIdentifier.Hash))
+	}
+	return &types.MempoolTransactionResponse{
+		Transaction: &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: tx.Hash},
+			Operations:            tx.Operations,
+			Metadata: map[string]interface{}{
+				"recent_blockhash": tx.Transaction.Message.RecentBlockHash,
+				"submitted":        tx.Submitted.UnixNano() / 1e6,
+			},
+		},
+	}, nil
+}
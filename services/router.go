@@ -15,6 +15,10 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/imerkle/rosetta-solana-go/configuration"
@@ -55,17 +59,58 @@ func NewBlockchainRouter(
 		asserter,
 	)
 
+	mempoolAPIService := NewMempoolAPIService(config, client)
+	mempoolAPIController := server.NewMempoolAPIController(
+		mempoolAPIService,
+		asserter,
+	)
+
 	callAPIService := NewCallAPIService(config, client)
 	callAPIController := server.NewCallAPIController(
 		callAPIService,
 		asserter,
 	)
 
-	return server.NewRouter(
+	return submitMetadataMiddleware(server.NewRouter(
 		networkAPIController,
 		accountAPIController,
 		blockAPIController,
 		constructionAPIController,
+		mempoolAPIController,
 		callAPIController,
-	)
+	))
+}
+
+type submitMetadataKey struct{}
+
+// submitMetadataMiddleware passes the metadata of /construction/submit
+// requests to ConstructionSubmit through the request context, as
+// types.ConstructionSubmitRequest has no metadata field.
+func submitMetadataMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/construction/submit" || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		var request struct {
+			Metadata map[string]interface{} `json:"metadata"`
+		}
+		if err := json.Unmarshal(body, &request); err == nil && request.Metadata != nil {
+			r = r.WithContext(context.WithValue(r.Context(), submitMetadataKey{}, request.Metadata))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// submitMetadata returns the /construction/submit metadata in ctx.
+func submitMetadata(ctx context.Context) map[string]interface{} {
+	m, _ := ctx.Value(submitMetadataKey{}).(map[string]interface{})
+	return m
 }
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	"github.com/imerkle/rosetta-solana-go/solana/operations"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+	"github.com/mitchellh/copystructure"
+)
+
+// batchTypes are the operation types whose source leg may pay more than
+// one destination leg in a single request.
+var batchTypes = []string{stypes.System__Transfer, stypes.SplToken__TransferWithSystem}
+
+// singleLegTypes are the operation types whose amount is not paired: tokens
+// are minted to or burned from a single account.
+var singleLegTypes = []string{stypes.SplToken__MintTo, stypes.SplToken__Burn}
+
+// transferGroup is the source leg of a transfer and its destination legs.
+// A batch payout has more than one destination.
+type transferGroup struct {
+	source       *types.Operation
+	destinations []*types.Operation
+}
+
+// operationPairs maps the index of each paired operation to the group it
+// is a leg of.
+type operationPairs map[int64]*transferGroup
+
+// pairOperations groups the source and destination legs of ops, which
+// may come in any order; the negative leg is the source.
+// Operations of the same type that relate to each other in
+// related_operations are grouped explicitly. Other operations with an
+// amount are paired with the earlier unpaired operation of the same type
+// and currency whose amount offsets theirs; when the pairs would depend on
+// that order, because the debits and credits of an amount are not each
+// from one account, they are ambiguous and have to be given in
+// related_operations. A source of a batch type that is left without a
+// destination pays the destinations that are left, if their amounts add
+// up to its own.
+func pairOperations(ops []*types.Operation) (operationPairs, *types.Error) {
+	byIndex := make(map[int64]*types.Operation, len(ops))
+	for _, op := range ops {
+		index := op.OperationIdentifier.Index
+		if _, ok := byIndex[index]; ok {
+			return nil, operationErr(index, operations.FieldErrorf("operation_identifier.index", "%d is used by more than one operation", index))
+		}
+		byIndex[index] = op
+	}
+
+	related := make(map[int64][]int64)
+	link := func(a int64, b int64) {
+		for _, v := range related[a] {
+			if v == b {
+				return
+			}
+		}
+		related[a] = append(related[a], b)
+	}
+	for _, op := range ops {
+		index := op.OperationIdentifier.Index
+		for _, r := range op.RelatedOperations {
+			if r == nil {
+				continue
+			}
+			v, ok := byIndex[r.Index]
+			if !ok || r.Index == index {
+				return nil, operationErr(index, operations.FieldErrorf("related_operations", "%d is not another operation of the request", r.Index))
+			}
+			// relations between operations of different types do not pair them
+			if v.Type != op.Type || solanago.Contains(singleLegTypes, op.Type) {
+				continue
+			}
+			link(index, r.Index)
+			link(r.Index, index)
+		}
+	}
+
+	pairs := make(operationPairs)
+	for _, op := range ops {
+		index := op.OperationIdentifier.Index
+		legs := related[index]
+		if _, ok := pairs[index]; ok || len(legs) == 0 {
+			continue
+		}
+		if len(legs) == 1 && len(related[legs[0]]) > 1 {
+			// op is a destination of the batch checked at its source
+			continue
+		}
+		group := &transferGroup{source: op}
+		for _, leg := range legs {
+			group.destinations = append(group.destinations, byIndex[leg])
+		}
+		if len(legs) > 1 {
+			if !solanago.Contains(batchTypes, op.Type) {
+				return nil, operationErr(index, operations.FieldErrorf("related_operations", "pairs operation %d with operations %v, a pair has two legs", index, legs))
+			}
+			for _, leg := range legs {
+				if len(related[leg]) > 1 {
+					return nil, operationErr(leg, operations.FieldErrorf("related_operations", "relates to more than one leg of a batch"))
+				}
+			}
+			if err := paysBatch(group); err != nil {
+				return nil, operationErr(index, err)
+			}
+		} else {
+			if err := offsets(op, group.destinations[0]); err != nil {
+				return nil, operationErr(index, err)
+			}
+			if !isNegative(op.Amount) {
+				group.source, group.destinations[0] = group.destinations[0], op
+			}
+		}
+		pairs.add(group)
+	}
+
+	implicit := func(op *types.Operation) bool {
+		_, ok := pairs[op.OperationIdentifier.Index]
+		return !ok && op.Amount != nil && !solanago.Contains(singleLegTypes, op.Type)
+	}
+	pairKey := func(op *types.Operation) string {
+		return op.Type + stypes.Separator + op.Amount.Currency.Symbol + stypes.Separator + absValue(op.Amount)
+	}
+	if err := checkImplicitPairs(ops, implicit, pairKey); err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string][]*types.Operation)
+	var unpaired []*types.Operation
+	for _, op := range ops {
+		if !implicit(op) {
+			continue
+		}
+		key := pairKey(op)
+		var candidates []int
+		for k, v := range pending[key] {
+			if isNegative(v.Amount) != isNegative(op.Amount) {
+				candidates = append(candidates, k)
+			}
+		}
+		if len(candidates) == 0 {
+			pending[key] = append(pending[key], op)
+			unpaired = append(unpaired, op)
+			continue
+		}
+		matched := pending[key][candidates[0]]
+		pending[key] = append(pending[key][:candidates[0]], pending[key][candidates[0]+1:]...)
+		group := &transferGroup{source: matched, destinations: []*types.Operation{op}}
+		if !isNegative(matched.Amount) {
+			group.source, group.destinations[0] = op, matched
+		}
+		pairs.add(group)
+	}
+
+	for _, op := range unpaired {
+		if _, ok := pairs[op.OperationIdentifier.Index]; ok || !isNegative(op.Amount) || !solanago.Contains(batchTypes, op.Type) {
+			continue
+		}
+		group := &transferGroup{source: op}
+		for _, v := range unpaired {
+			if _, ok := pairs[v.OperationIdentifier.Index]; !ok && v.Type == op.Type && v.Amount.Currency.Symbol == op.Amount.Currency.Symbol && !isNegative(v.Amount) {
+				group.destinations = append(group.destinations, v)
+			}
+		}
+		if len(group.destinations) > 1 && paysBatch(group) == nil {
+			pairs.add(group)
+		}
+	}
+	return pairs, nil
+}
+
+// pairSide is the debits or the credits of an amount that are paired by
+// their order.
+type pairSide struct {
+	legs     int
+	accounts []*types.AccountIdentifier
+}
+
+func (side *pairSide) add(account *types.AccountIdentifier) {
+	side.legs++
+	for _, v := range side.accounts {
+		if sameAccount(v, account) {
+			return
+		}
+	}
+	side.accounts = append(side.accounts, account)
+}
+
+// checkImplicitPairs rejects the operations paired by their order when
+// another order would pair them with other accounts: the debits and the
+// credits of an amount both come from more than one account, or the side
+// with more than one account has legs left over.
+func checkImplicitPairs(ops []*types.Operation, implicit func(*types.Operation) bool, pairKey func(*types.Operation) string) *types.Error {
+	var keys []string
+	first := make(map[string]*types.Operation)
+	debits := make(map[string]*pairSide)
+	credits := make(map[string]*pairSide)
+	for _, op := range ops {
+		if !implicit(op) {
+			continue
+		}
+		key := pairKey(op)
+		if _, ok := first[key]; !ok {
+			keys = append(keys, key)
+			first[key] = op
+			debits[key], credits[key] = &pairSide{}, &pairSide{}
+		}
+		if isNegative(op.Amount) {
+			debits[key].add(op.Account)
+		} else {
+			credits[key].add(op.Account)
+		}
+	}
+	for _, key := range keys {
+		d, c := debits[key], credits[key]
+		if d.legs == 0 || c.legs == 0 {
+			continue
+		}
+		if (len(d.accounts) > 1 && len(c.accounts) > 1) || (len(d.accounts) > 1 && d.legs > c.legs) || (len(c.accounts) > 1 && c.legs > d.legs) {
+			op := first[key]
+			return operationErr(op.OperationIdentifier.Index, operations.FieldErrorf("amount", "offsets %s operations of more than one account, set related_operations to pair it", op.Type))
+		}
+	}
+	return nil
+}
+
+func (pairs operationPairs) add(group *transferGroup) {
+	pairs[group.source.OperationIdentifier.Index] = group
+	for _, destination := range group.destinations {
+		pairs[destination.OperationIdentifier.Index] = group
+	}
+}
+
+// offsets checks that the amount of op is the negative of the amount of
+// the operation it is paired with.
+func offsets(op *types.Operation, other *types.Operation) error {
+	otherIndex := other.OperationIdentifier.Index
+	if op.Amount == nil || other.Amount == nil {
+		return operations.FieldErrorf("amount", "is required to pair with operation %d", otherIndex)
+	}
+	if op.Amount.Currency.Symbol != other.Amount.Currency.Symbol {
+		return operations.FieldErrorf("amount.currency", "differs from operation %d", otherIndex)
+	}
+	if isNegative(op.Amount) == isNegative(other.Amount) || absValue(op.Amount) != absValue(other.Amount) {
+		return operations.FieldErrorf("amount.value", "does not offset operation %d", otherIndex)
+	}
+	return nil
+}
+
+// paysBatch checks that the source of group pays exactly the amounts of
+// its destinations.
+func paysBatch(group *transferGroup) error {
+	source := group.source
+	if source.Amount == nil || !isNegative(source.Amount) {
+		return operations.FieldErrorf("amount.value", "must be negative, the source of a batch pays its destinations")
+	}
+	total := new(big.Int)
+	for _, destination := range group.destinations {
+		index := destination.OperationIdentifier.Index
+		if destination.Amount == nil || isNegative(destination.Amount) {
+			return operations.FieldErrorf("related_operations", "operation %d is not a destination of the batch", index)
+		}
+		if destination.Amount.Currency.Symbol != source.Amount.Currency.Symbol {
+			return operations.FieldErrorf("amount.currency", "differs from operation %d", index)
+		}
+		value, _ := new(big.Int).SetString(destination.Amount.Value, 10)
+		total.Add(total, value)
+	}
+	if total.String() != absValue(source.Amount) {
+		return operations.FieldErrorf("amount.value", "does not offset the %s paid to operations of the batch", total)
+	}
+	return nil
+}
+
+func isNegative(amount *types.Amount) bool {
+	return strings.HasPrefix(amount.Value, "-")
+}
+
+// absValue returns the amount without its sign, in canonical form.
+func absValue(amount *types.Amount) string {
+	value, ok := new(big.Int).SetString(amount.Value, 10)
+	if !ok {
+		return strings.TrimPrefix(amount.Value, "-")
+	}
+	return value.Abs(value).String()
+}
+
+func sameAccount(a *types.AccountIdentifier, b *types.AccountIdentifier) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Address == b.Address && solanago.SubAccountAddress(a) == solanago.SubAccountAddress(b)
+}
+
+// mergeOperations folds each transfer of ops into a copy of its source leg
+// whose metadata carries the source and destination of the transfer, in
+// the order of their first leg. A batch is folded into one operation per
+// destination, with the index of the destination leg.
+func mergeOperations(ops []*types.Operation) ([]*types.Operation, *types.Error) {
+	pairs, err := pairOperations(ops)
+	if err != nil {
+		return nil, err
+	}
+	var merged []*types.Operation
+	done := make(map[int64]bool)
+	for _, op := range ops {
+		index := op.OperationIdentifier.Index
+		if done[index] {
+			continue
+		}
+		group, ok := pairs[index]
+		if !ok {
+			if op.Amount != nil && !solanago.Contains(singleLegTypes, op.Type) {
+				return nil, operationErr(index, operations.FieldErrorf("amount", "has no %s operation with the opposite amount", op.Type))
+			}
+			tmpOP, err := copyOperation(op)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, tmpOP)
+			done[index] = true
+			continue
+		}
+		for _, destination := range group.destinations {
+			tmpOP, err := mergeTransfer(group.source, destination)
+			if err != nil {
+				return nil, err
+			}
+			if len(group.destinations) > 1 {
+				tmpOP.OperationIdentifier = destination.OperationIdentifier
+			}
+			merged = append(merged, tmpOP)
+			done[destination.OperationIdentifier.Index] = true
+		}
+		done[group.source.OperationIdentifier.Index] = true
+	}
+	return merged, nil
+}
+
+// mergeTransfer returns a copy of fromOp with the source and destination
+// of the transfer to toOp in its metadata.
+func mergeTransfer(fromOp *types.Operation, toOp *types.Operation) (*types.Operation, *types.Error) {
+	tmpOP, err := copyOperation(fromOp)
+	if err != nil {
+		return nil, err
+	}
+	fromAdd := solanago.SubAccountAddress(fromOp.Account)
+	toAdd := solanago.SubAccountAddress(toOp.Account)
+	tmpOP.Metadata["source"] = fromAdd
+	tmpOP.Metadata["destination"] = toAdd
+	// the owner of a token or stake sub-account signs for it
+	if _, ok := tmpOP.Metadata["authority"]; !ok && fromAdd != fromOp.Account.Address {
+		tmpOP.Metadata["authority"] = fromOp.Account.Address
+	}
+	tmpOP.Amount = toOp.Amount
+	return tmpOP, nil
+}
+
+func copyOperation(op *types.Operation) (*types.Operation, *types.Error) {
+	opCopy, err := copystructure.Copy(*op)
+	if err != nil {
+		return nil, wrapErr(ErrUnclearIntent, fmt.Errorf("Cannot deep copy operations"))
+	}
+	tmpOP := opCopy.(types.Operation)
+	if tmpOP.Metadata == nil {
+		tmpOP.Metadata = make(map[string]interface{})
+	}
+	return &tmpOP, nil
+}
@@ -51,6 +51,13 @@ type Client interface {
 		ctx context.Context,
 		request *types.CallRequest,
 	) (*types.CallResponse, error)
+
+	Coins(
+		context.Context,
+		*types.AccountIdentifier,
+		bool,
+		[]*types.Currency,
+	) (*types.AccountCoinsResponse, error)
 }
 type ConstructionMetadata struct {
 	BlockHash         string                        `json:"blockhash,omitempty"`
@@ -60,9 +67,29 @@ type ConstructionMetadata struct {
 	SplTokenAccMapKey map[string]stypes.SplAccounts `json:"spl_token_acc_map"`
 	WithNonce         stypes.WithNonce              `json:"with_nonce"`
 	FeeCalculation    stypes.FeeCalculation         `json:"fee_calculation,omitempty"`
+	Nonce             stypes.NonceMetadata          `json:"nonce"`
+	StakeRentExempt   uint64                        `json:"stake_rent_exempt,omitempty"`
+	FeePayer          string                        `json:"fee_payer,omitempty"`
 }
 
 type MetadataWithFee struct {
 	Metadata     ConstructionMetadata `json:"metadata"`
 	SuggestedFee []*types.Amount      `json:"suggestedFee"`
 }
+
+// DeriveMetadata is the metadata of /construction/derive. With a mint the
+// associated token account of the key is derived, with a program ID the
+// program derived address of the seeds.
+type DeriveMetadata struct {
+	Mint      string       `json:"mint,omitempty"`
+	ProgramID string       `json:"program_id,omitempty"`
+	Seeds     []DeriveSeed `json:"seeds,omitempty"`
+}
+
+// DeriveSeed is a seed of a program derived address, given in exactly one
+// of its encodings.
+type DeriveSeed struct {
+	UTF8   string `json:"utf8,omitempty"`
+	Hex    string `json:"hex,omitempty"`
+	Base58 string `json:"base58,omitempty"`
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	"github.com/imerkle/rosetta-solana-go/solana/operations"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+)
+
+// constructionPrograms are the operation type prefixes ToInstructions
+// builds instructions for.
+var constructionPrograms = []string{"System", "SplToken", "SplAssociatedTokenAccount", "Stake", "Memo", "Generic"}
+
+// validateOperations checks the identifiers, types, accounts and amounts
+// of ops before they are paired, so that invalid operations are rejected
+// with the field at fault instead of building wrong instructions.
+func validateOperations(ops []*types.Operation) *types.Error {
+	for i, op := range ops {
+		if op == nil || op.OperationIdentifier == nil {
+			return operationErr(int64(i), operations.FieldErrorf("operation_identifier", "is required"))
+		}
+		if err := validateOperation(op); err != nil {
+			return operationErr(op.OperationIdentifier.Index, err)
+		}
+	}
+	return nil
+}
+
+func validateOperation(op *types.Operation) error {
+	program := strings.Split(op.Type, stypes.Separator)[0]
+	if !solanago.Contains(constructionPrograms, program) {
+		return operations.FieldErrorf("type", "%s is not supported for construction", op.Type)
+	}
+
+	if op.Account == nil {
+		if op.Type != stypes.Memo__Memo && op.Type != stypes.Generic__Instruction {
+			return operations.FieldErrorf("account", "is required")
+		}
+	} else {
+		if !operations.IsAddress(op.Account.Address) {
+			return operations.FieldErrorf("account.address", "%s is not a valid public key", op.Account.Address)
+		}
+		if op.Account.SubAccount != nil && !operations.IsAddress(op.Account.SubAccount.Address) {
+			return operations.FieldErrorf("account.sub_account.address", "%s is not a valid public key", op.Account.SubAccount.Address)
+		}
+	}
+
+	if op.Amount == nil {
+		return nil
+	}
+	if op.Type == stypes.Generic__Instruction {
+		return operations.FieldErrorf("amount", "is not used by %s", op.Type)
+	}
+	if op.Amount.Currency == nil {
+		return operations.FieldErrorf("amount.currency", "is required")
+	}
+	value, ok := new(big.Int).SetString(op.Amount.Value, 10)
+	if !ok {
+		return operations.FieldErrorf("amount.value", "%s is not an integer", op.Amount.Value)
+	}
+	if !new(big.Int).Abs(value).IsUint64() {
+		return operations.FieldErrorf("amount.value", "%s does not fit in 64 bits", op.Amount.Value)
+	}
+	currency := op.Amount.Currency
+	switch {
+	case program == "System" || program == "Stake" || op.Type == stypes.SplToken__WrapSol:
+		if currency.Symbol != stypes.Symbol || currency.Decimals != stypes.Decimals {
+			return operations.FieldErrorf("amount.currency", "must be %s with %d decimals", stypes.Symbol, stypes.Decimals)
+		}
+	case program == "SplToken":
+		if !operations.IsAddress(currency.Symbol) {
+			return operations.FieldErrorf("amount.currency.symbol", "%s is not a mint address", currency.Symbol)
+		}
+		if currency.Decimals < 0 || currency.Decimals > 255 {
+			return operations.FieldErrorf("amount.currency.decimals", "%d is out of range", currency.Decimals)
+		}
+	}
+	switch {
+	case op.Type == stypes.SplToken__MintTo && value.Sign() <= 0:
+		return operations.FieldErrorf("amount.value", "must be positive, tokens are minted to the account")
+	case op.Type == stypes.SplToken__Burn && value.Sign() >= 0:
+		return operations.FieldErrorf("amount.value", "must be negative, tokens are burned from the account")
+	}
+	return nil
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"github.com/imerkle/rosetta-solana-go/solana/shared_types"
 	"log"
+	"math/big"
 	"strconv"
 
 	ss "github.com/blocto/solana-go-sdk/client"
@@ -28,13 +29,14 @@ import (
 type Client struct {
 	Rpc          *ss.Client
 	directClient *DirectClient
+	Mempool      *Mempool
 }
 
 // NewClient creates a Client that from the provided url and params.
 func NewClient(url string) (*Client, error) {
 	rpc := ss.NewClient(url)
 	directClient := NewDirectClient(url)
-	return &Client{Rpc: rpc, directClient: directClient}, nil
+	return &Client{Rpc: rpc, directClient: directClient, Mempool: NewMempool(DefaultMempoolSize)}, nil
 }
 
 // Close shuts down the RPC client connection.
@@ -82,10 +84,51 @@ func (ec *Client) BlockTransaction(
 	if err != nil {
 		return nil, err
 	}
-	rosTx := ToRosTx(tx.Transaction)
+	withdrawers, err := ec.StakeWithdrawers(ctx, tx.Transaction)
+	if err != nil {
+		return nil, err
+	}
+	rosTx := ToRosTx(tx.Transaction, tx.Meta, withdrawers)
 	return &rosTx, nil
 }
 
+// maxMultipleAccounts is the most accounts getMultipleAccounts accepts in
+// one request.
+const maxMultipleAccounts = 100
+
+// StakeWithdrawers fetches the withdraw authorities of the stake accounts
+// of txs that the transactions do not name themselves, so that every
+// operation on a stake account is keyed by its withdrawer. Closed
+// accounts are left out.
+func (ec *Client) StakeWithdrawers(ctx context.Context, txs ...shared_types.ParsedTransaction) (map[string]string, error) {
+	var accounts []string
+	for _, tx := range txs {
+		named := StakeAccountWithdrawers(tx, nil)
+		for _, account := range StakeAccounts(tx) {
+			if _, ok := named[account]; !ok && !Contains(accounts, account) {
+				accounts = append(accounts, account)
+			}
+		}
+	}
+	withdrawers := make(map[string]string)
+	for start := 0; start < len(accounts); start += maxMultipleAccounts {
+		end := start + maxMultipleAccounts
+		if end > len(accounts) {
+			end = len(accounts)
+		}
+		accs, err := ec.directClient.GetMultipleAccountsParsed(ctx, accounts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for k, acc := range accs {
+			if acc != nil && acc.Data.Program == "stake" && acc.Data.Parsed.Info.Meta.Authorized.Withdrawer != "" {
+				withdrawers[accounts[start+k]] = acc.Data.Parsed.Info.Meta.Authorized.Withdrawer
+			}
+		}
+	}
+	return withdrawers, nil
+}
+
 // Block returns a populated block at the *RosettaTypes.PartialBlockIdentifier.
 // If neither the hash or index is populated in the *RosettaTypes.PartialBlockIdentifier,
 // the current block is returned.
@@ -99,6 +142,14 @@ func (ec *Client) Block(
 			if err != nil {
 				return nil, err
 			}
+			txs := make([]shared_types.ParsedTransaction, len(blockResponse.Transactions))
+			for k, tx := range blockResponse.Transactions {
+				txs[k] = tx.Transaction
+			}
+			withdrawers, err := ec.StakeWithdrawers(ctx, txs...)
+			if err != nil {
+				return nil, err
+			}
 			return &RosettaTypes.Block{
 				BlockIdentifier: &RosettaTypes.BlockIdentifier{
 					Index: *blockIdentifier.Index,
@@ -106,7 +157,7 @@ func (ec *Client) Block(
 				},
 				ParentBlockIdentifier: &RosettaTypes.BlockIdentifier{Index: int64(blockResponse.ParentSlot), Hash: blockResponse.PreviousBlockhash},
 				Timestamp:             convertTime(uint64(blockResponse.BlockTime)),
-				Transactions:          ToRosTxs(blockResponse.Transactions),
+				Transactions:          ToRosTxs(blockResponse.Transactions, withdrawers),
 				Metadata:              map[string]interface{}{},
 			}, nil
 		}
@@ -131,12 +182,46 @@ func (ec *Client) Balance(
 	if block != nil {
 		return nil, fmt.Errorf("block hash balance not supported")
 	}
+	if account.SubAccount != nil {
+		balances, metadata, err := ec.subAccountBalance(ctx, account)
+		if err != nil {
+			return nil, err
+		}
+		slot, err := ec.Rpc.GetSlot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &RosettaTypes.AccountBalanceResponse{
+			BlockIdentifier: &RosettaTypes.BlockIdentifier{
+				Hash:  strconv.FormatInt(int64(slot), 10),
+				Index: int64(slot),
+			},
+			Balances: balances,
+			Metadata: metadata,
+		}, nil
+	}
 
-	bal, err := ec.Rpc.GetBalance(ctx, account.Address)
-	log.Printf("after rpc.getBalance")
-	if err != nil {
-		return nil, err
+	// the parsed account has the lamports and the state of nonce and stake
+	// accounts, so the balance needs a single request; its metadata is
+	// best effort and does not fail the balance
+	var bal uint64
+	var metadata map[string]interface{}
+	acc, err := ec.directClient.GetAccountInfoParsed(ctx, account.Address)
+	if err == nil {
+		bal = acc.Lamports
+		metadata, err = ec.accountMetadata(ctx, account.Address, acc)
+		if err != nil {
+			log.Printf("metadata of %s is unavailable: %v", account.Address, err)
+			metadata = nil
+		}
+	} else {
+		log.Printf("account info of %s is unavailable: %v", account.Address, err)
+		bal, err = ec.Rpc.GetBalance(ctx, account.Address)
+		if err != nil {
+			return nil, err
+		}
 	}
+	log.Printf("after rpc.getBalance")
 	var balances []*RosettaTypes.Amount
 	nativeBalance := &RosettaTypes.Amount{
 		Value: fmt.Sprint(bal),
@@ -169,6 +254,9 @@ func (ec *Client) Balance(
 	balances = append(balances, nativeBalance)
 	//}
 	slot, err := ec.Rpc.GetSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	log.Printf("END Balance")
 	return &RosettaTypes.AccountBalanceResponse{
@@ -178,10 +266,185 @@ func (ec *Client) Balance(
 			Index: int64(slot),
 		},
 		Balances: balances,
-		Metadata: nil,
+		Metadata: metadata,
+	}, nil
+}
+
+// Coins returns one coin per token account owned by account, identified
+// by the token account address. With includeMempool the accounts are read
+// at processed commitment, so transactions not yet confirmed are included.
+func (ec *Client) Coins(
+	ctx context.Context,
+	account *RosettaTypes.AccountIdentifier,
+	includeMempool bool,
+	currencies []*RosettaTypes.Currency,
+) (*RosettaTypes.AccountCoinsResponse, error) {
+	commitment := ""
+	if includeMempool {
+		commitment = "processed"
+	}
+	tokenAccs, err := ec.directClient.GetTokenAccountsByOwnerWithCommitment(ctx, account.Address, commitment)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := ec.Rpc.GetSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	coins := []*RosettaTypes.Coin{}
+	for _, tokenAcc := range tokenAccs {
+		info := tokenAcc.Account.Data.Parsed.Info
+		amount := tokenBalance(info.Mint, info.TokenAmount.Decimals, info.TokenAmount.Amount)
+		if len(currencies) > 0 && !containsCurrency(currencies, amount.Currency) {
+			continue
+		}
+		coins = append(coins, &RosettaTypes.Coin{
+			CoinIdentifier: &RosettaTypes.CoinIdentifier{Identifier: tokenAcc.Pubkey},
+			Amount:         amount,
+		})
+	}
+	return &RosettaTypes.AccountCoinsResponse{
+		BlockIdentifier: &RosettaTypes.BlockIdentifier{
+			Hash:  strconv.FormatInt(int64(slot), 10),
+			Index: int64(slot),
+		},
+		Coins: coins,
 	}, nil
 }
 
+func containsCurrency(currencies []*RosettaTypes.Currency, currency *RosettaTypes.Currency) bool {
+	for _, c := range currencies {
+		if c.Symbol == currency.Symbol && c.Decimals == currency.Decimals {
+			return true
+		}
+	}
+	return false
+}
+
+// subAccountBalance returns the balance of a sub-account of
+// account.Address: a token account it owns, all its token accounts of a
+// mint, or a stake account it can withdraw from.
+func (ec *Client) subAccountBalance(ctx context.Context, account *RosettaTypes.AccountIdentifier) ([]*RosettaTypes.Amount, map[string]interface{}, error) {
+	subAccount := account.SubAccount.Address
+	acc, err := ec.directClient.GetAccountInfoParsed(ctx, subAccount)
+	if err != nil {
+		return nil, nil, err
+	}
+	info := acc.Data.Parsed.Info
+	switch {
+	case acc.Data.Program == "spl-token" && acc.Data.Parsed.Type == "account":
+		if info.Owner != account.Address {
+			return nil, nil, fmt.Errorf("token account %s is not owned by %s", subAccount, account.Address)
+		}
+		return []*RosettaTypes.Amount{tokenBalance(info.Mint, info.TokenAmount.Decimals, info.TokenAmount.Amount)}, nil, nil
+	case acc.Data.Program == "spl-token" && acc.Data.Parsed.Type == "mint":
+		tokenAccs, err := ec.directClient.GetTokenAccountsByMint(ctx, account.Address, subAccount)
+		if err != nil {
+			return nil, nil, err
+		}
+		total := new(big.Int)
+		for _, tokenAcc := range tokenAccs {
+			amount, ok := new(big.Int).SetString(tokenAcc.Account.Data.Parsed.Info.TokenAmount.Amount, 10)
+			if ok {
+				total.Add(total, amount)
+			}
+		}
+		return []*RosettaTypes.Amount{tokenBalance(subAccount, info.Decimals, total.String())}, nil, nil
+	case acc.Data.Program == "stake":
+		if info.Meta.Authorized.Withdrawer != account.Address {
+			return nil, nil, fmt.Errorf("%s is not the withdraw authority of stake account %s", account.Address, subAccount)
+		}
+		metadata, err := ec.stakeMetadata(ctx, subAccount, acc)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*RosettaTypes.Amount{{Value: fmt.Sprint(acc.Lamports), Currency: shared_types.Currency}}, metadata, nil
+	}
+	return nil, nil, fmt.Errorf("sub-account %s is not a token account, mint or stake account", subAccount)
+}
+
+func tokenBalance(mint string, decimals int32, amount string) *RosettaTypes.Amount {
+	return &RosettaTypes.Amount{
+		Value: amount,
+		Currency: &RosettaTypes.Currency{
+			Symbol:   mint,
+			Decimals: decimals,
+		},
+	}
+}
+
+// accountMetadata returns the program specific state of an account
+// for the /account/balance metadata, or nil for plain system accounts.
+func (ec *Client) accountMetadata(ctx context.Context, address string, acc GetAccountInfoParsedResponse) (map[string]interface{}, error) {
+	switch acc.Data.Program {
+	case "nonce":
+		nonce, err := ToNonceAccount(acc)
+		if err != nil {
+			return map[string]interface{}{"nonce": map[string]interface{}{"state": acc.Data.Parsed.Type}}, nil
+		}
+		return map[string]interface{}{
+			"nonce": map[string]interface{}{
+				"state":                  acc.Data.Parsed.Type,
+				"authority":              nonce.Authority,
+				"blockhash":              nonce.BlockHash,
+				"lamports_per_signature": nonce.LamportsPerSignature,
+			},
+		}, nil
+	case "stake":
+		return ec.stakeMetadata(ctx, address, acc)
+	}
+	return nil, nil
+}
+
+// stakeMetadata describes the authorities, lockup and delegation of a
+// stake account. Staked is the part of the balance that is delegated or
+// still activating, liquid is everything else including the rent-exempt
+// reserve.
+func (ec *Client) stakeMetadata(ctx context.Context, address string, acc GetAccountInfoParsedResponse) (map[string]interface{}, error) {
+	info := acc.Data.Parsed.Info
+	activation, err := ec.directClient.GetStakeActivation(ctx, address)
+	if err != nil {
+		epochInfo, err := ec.Rpc.GetEpochInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		activation = ToStakeActivation(acc, epochInfo.Epoch)
+	}
+	stake := map[string]interface{}{
+		"state":      acc.Data.Parsed.Type,
+		"staker":     info.Meta.Authorized.Staker,
+		"withdrawer": info.Meta.Authorized.Withdrawer,
+		"lockup": map[string]interface{}{
+			"unix_timestamp": info.Meta.Lockup.UnixTimestamp,
+			"epoch":          info.Meta.Lockup.Epoch,
+			"custodian":      info.Meta.Lockup.Custodian,
+		},
+		"rent_exempt_reserve": info.Meta.RentExemptReserve,
+		"activation_state":    activation.State,
+		"active":              fmt.Sprint(activation.Active),
+		"inactive":            fmt.Sprint(activation.Inactive),
+	}
+	staked := activation.Active
+	if activation.State == "activating" {
+		staked = ValueToBaseAmount(info.Stake.Delegation.Stake)
+	}
+	if staked > acc.Lamports {
+		staked = acc.Lamports
+	}
+	stake["staked"] = fmt.Sprint(staked)
+	stake["liquid"] = fmt.Sprint(acc.Lamports - staked)
+	if acc.Data.Parsed.Type == "delegated" {
+		delegation := info.Stake.Delegation
+		stake["delegation"] = map[string]interface{}{
+			"voter":              delegation.Voter,
+			"stake":              delegation.Stake,
+			"activation_epoch":   delegation.ActivationEpoch,
+			"deactivation_epoch": delegation.DeactivationEpoch,
+		}
+	}
+	return map[string]interface{}{"stake": stake}, nil
+}
+
 // Call handles calls to the /call endpoint.
 func (ec *Client) Call(
 	ctx context.Context,
@@ -0,0 +1,415 @@
+package solanago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	solPTypes "github.com/blocto/solana-go-sdk/types"
+	RosettaTypes "github.com/coinbase/rosetta-sdk-go/types"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+	"github.com/test-go/testify/assert"
+)
+
+// testNode records the JSON-RPC requests of a test client and serves
+// results by method name.
+type testNode struct {
+	mu       sync.Mutex
+	results  map[string]string
+	received []map[string]interface{}
+}
+
+// set replaces the result served for method.
+func (n *testNode) set(method, result string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.results[method] = result
+}
+
+// requests returns the requests received so far.
+func (n *testNode) requests() []map[string]interface{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]map[string]interface{}(nil), n.received...)
+}
+
+// reset forgets the requests received so far.
+func (n *testNode) reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.received = nil
+}
+
+// newTestClient serves the given JSON-RPC results by method name.
+func newTestClient(t *testing.T, results map[string]string) (*Client, *testNode) {
+	node := &testNode{results: results}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var req map[string]interface{}
+		json.Unmarshal(body, &req)
+		node.mu.Lock()
+		node.received = append(node.received, req)
+		result, ok := node.results[req["method"].(string)]
+		node.mu.Unlock()
+		if !ok {
+			result = "null"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":0,"result":` + result + `}`))
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewClient(server.URL)
+	assert.NoError(t, err)
+	return client, node
+}
+
+func TestCoins(t *testing.T) {
+	client, node := newTestClient(t, map[string]string{
+		"getSlot": "42",
+		"getTokenAccountsByOwner": `{"context":{"slot":42},"value":[
+			{"pubkey":"95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV","account":{"lamports":2039280,"owner":"TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA","data":{"program":"spl-token","parsed":{"type":"account","info":{"mint":"3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr","owner":"HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH","tokenAmount":{"amount":"150","decimals":2}}}}}},
+			{"pubkey":"GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L","account":{"lamports":2039280,"owner":"TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA","data":{"program":"spl-token","parsed":{"type":"account","info":{"mint":"So11111111111111111111111111111111111111112","owner":"HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH","tokenAmount":{"amount":"7","decimals":9}}}}}}
+		]}`,
+	})
+	account := &RosettaTypes.AccountIdentifier{Address: "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"}
+
+	res, err := client.Coins(context.Background(), account, false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), res.BlockIdentifier.Index)
+	assert.Equal(t, 2, len(res.Coins))
+	assert.Equal(t, "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV", res.Coins[0].CoinIdentifier.Identifier)
+	assert.Equal(t, "150", res.Coins[0].Amount.Value)
+	assert.Equal(t, &RosettaTypes.Currency{Symbol: "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr", Decimals: 2}, res.Coins[0].Amount.Currency)
+
+	res, err = client.Coins(context.Background(), account, true, []*RosettaTypes.Currency{{Symbol: "So11111111111111111111111111111111111111112", Decimals: 9}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res.Coins))
+	assert.Equal(t, "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L", res.Coins[0].CoinIdentifier.Identifier)
+
+	var commitment interface{}
+	for _, req := range node.requests() {
+		if req["method"] == "getTokenAccountsByOwner" {
+			params := req["params"].([]interface{})
+			commitment = params[2].(map[string]interface{})["commitment"]
+		}
+	}
+	assert.Equal(t, "processed", commitment)
+}
+
+func TestBalance(t *testing.T) {
+	account := &RosettaTypes.AccountIdentifier{Address: "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"}
+	methods := func(requests []map[string]interface{}) []interface{} {
+		var m []interface{}
+		for _, req := range requests {
+			m = append(m, req["method"])
+		}
+		return m
+	}
+
+	// a system account is read with a single request
+	client, node := newTestClient(t, map[string]string{
+		"getSlot":        "42",
+		"getAccountInfo": `{"context":{"slot":42},"value":{"lamports":1500,"owner":"11111111111111111111111111111111","data":["","base64"]}}`,
+	})
+	res, err := client.Balance(context.Background(), account, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "1500", res.Balances[0].Value)
+	assert.Nil(t, res.Metadata)
+	assert.Equal(t, []interface{}{"getAccountInfo", "getSlot"}, methods(node.requests()))
+
+	// without the parsed account the balance is still returned
+	client, node = newTestClient(t, map[string]string{
+		"getSlot":        "42",
+		"getAccountInfo": `"unavailable"`,
+		"getBalance":     `{"context":{"slot":42},"value":700}`,
+	})
+	res, err = client.Balance(context.Background(), account, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "700", res.Balances[0].Value)
+	assert.Equal(t, []interface{}{"getAccountInfo", "getBalance", "getSlot"}, methods(node.requests()))
+
+	// and without the metadata of a stake account
+	client, _ = newTestClient(t, map[string]string{
+		"getSlot":            "42",
+		"getAccountInfo":     `{"context":{"slot":42},"value":{"lamports":2282880,"owner":"Stake11111111111111111111111111111111111111","data":{"program":"stake","parsed":{"type":"initialized","info":{}}}}}`,
+		"getStakeActivation": `"unavailable"`,
+		"getEpochInfo":       `"unavailable"`,
+	})
+	res, err = client.Balance(context.Background(), account, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2282880", res.Balances[0].Value)
+	assert.Nil(t, res.Metadata)
+}
+
+func TestStakeWithdrawers(t *testing.T) {
+	client, node := newTestClient(t, map[string]string{
+		"getMultipleAccounts": `{"context":{"slot":42},"value":[
+			{"lamports":2282880,"owner":"Stake11111111111111111111111111111111111111","data":{"program":"stake","parsed":{"type":"initialized","info":{"meta":{"authorized":{"staker":"CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n","withdrawer":"HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"}}}}}},
+			null
+		]}`,
+	})
+	var tx stypes.ParsedTransaction
+	err := json.Unmarshal([]byte(`{
+		"signatures": ["5Jx"],
+		"message": {
+			"instructions": [
+				{"program": "stake", "parsed": {"type": "withdraw", "info": {"stakeAccount": "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm", "withdrawAuthority": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"}}},
+				{"program": "stake", "parsed": {"type": "deactivate", "info": {"stakeAccount": "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L"}}},
+				{"program": "stake", "parsed": {"type": "deactivate", "info": {"stakeAccount": "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV"}}}
+			]
+		}
+	}`), &tx)
+	assert.NoError(t, err)
+
+	withdrawers, err := client.StakeWithdrawers(context.Background(), tx)
+	assert.NoError(t, err)
+	// the withdrawn account names its withdrawer and the closed one is left out
+	assert.Equal(t, map[string]string{"GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"}, withdrawers)
+	requests := node.requests()
+	assert.Equal(t, 1, len(requests))
+	assert.Equal(t, []interface{}{"GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L", "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV"}, requests[0]["params"].([]interface{})[0])
+
+	// nothing is fetched when the transaction names every withdrawer
+	node.reset()
+	tx.Message.Instructions = tx.Message.Instructions[:1]
+	withdrawers, err = client.StakeWithdrawers(context.Background(), tx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(withdrawers))
+	assert.Equal(t, 0, len(node.requests()))
+}
+
+func TestMempool(t *testing.T) {
+	results := map[string]string{
+		"getSignatureStatuses": `{"context":{"slot":7},"value":[{"slot":5,"confirmations":null,"err":null,"confirmationStatus":"confirmed"},null]}`,
+		"isBlockhashValid":     `{"context":{"slot":7},"value":true}`,
+	}
+	client, node := newTestClient(t, results)
+
+	from := common.PublicKeyFromString("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH")
+	to := common.PublicKeyFromString("95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV")
+	tx, err := solPTypes.NewTransaction(solPTypes.NewTransactionParam{
+		Message: solPTypes.NewMessage(solPTypes.NewMessageParam{
+			FeePayer:        from,
+			RecentBlockhash: "9rAtxuhtKn8qagc3UtZFyhLrw5zgh6rLakLbHbQTGPFi",
+			Instructions: []solPTypes.Instruction{
+				system.Transfer(system.TransferParam{From: from, To: to, Amount: 10}),
+			},
+		}),
+	})
+	assert.NoError(t, err)
+
+	assert.True(t, client.Mempool.Add("a", tx, nil))
+	assert.True(t, client.Mempool.Add("b", tx, nil))
+	pending, ok := client.Mempool.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(pending.Operations))
+	assert.Nil(t, pending.Operations[0].Status)
+
+	assert.NoError(t, client.RefreshMempool(context.Background()))
+	txs := client.Mempool.Transactions()
+	assert.Equal(t, 1, len(txs))
+	assert.Equal(t, "b", txs[0].Hash)
+
+	node.set("getSignatureStatuses", `{"context":{"slot":8},"value":[null]}`)
+	node.set("isBlockhashValid", `{"context":{"slot":8},"value":false}`)
+	assert.NoError(t, client.RefreshMempool(context.Background()))
+	assert.Equal(t, 0, len(client.Mempool.Transactions()))
+}
+
+func TestRefreshMempool(t *testing.T) {
+	from := common.PublicKeyFromString("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH")
+	tx, err := solPTypes.NewTransaction(solPTypes.NewTransactionParam{
+		Message: solPTypes.NewMessage(solPTypes.NewMessageParam{
+			FeePayer:        from,
+			RecentBlockhash: "9rAtxuhtKn8qagc3UtZFyhLrw5zgh6rLakLbHbQTGPFi",
+		}),
+	})
+	assert.NoError(t, err)
+	count := func(requests []map[string]interface{}, method string) int {
+		n := 0
+		for _, req := range requests {
+			if req["method"] == method {
+				n++
+			}
+		}
+		return n
+	}
+
+	// the validity of a blockhash is fetched once per refresh
+	client, node := newTestClient(t, map[string]string{
+		"getSignatureStatuses": `{"context":{"slot":7},"value":[null,null,null]}`,
+		"isBlockhashValid":     `{"context":{"slot":7},"value":true}`,
+	})
+	for _, hash := range []string{"a", "b", "c"} {
+		assert.True(t, client.Mempool.Add(hash, tx, nil))
+	}
+	assert.NoError(t, client.RefreshMempool(context.Background()))
+	assert.Equal(t, 3, len(client.Mempool.Transactions()))
+	assert.Equal(t, 1, count(node.requests(), "isBlockhashValid"))
+
+	// transactions whose validity cannot be fetched are kept, the others
+	// are still refreshed
+	client, _ = newTestClient(t, map[string]string{
+		"getSignatureStatuses": `{"context":{"slot":7},"value":[{"slot":5,"confirmations":null,"err":null,"confirmationStatus":"confirmed"},null]}`,
+		"isBlockhashValid":     `"unavailable"`,
+	})
+	assert.True(t, client.Mempool.Add("a", tx, nil))
+	assert.True(t, client.Mempool.Add("b", tx, nil))
+	assert.Error(t, client.RefreshMempool(context.Background()))
+	txs := client.Mempool.Transactions()
+	assert.Equal(t, 1, len(txs))
+	assert.Equal(t, "b", txs[0].Hash)
+}
+
+func TestWaitForConfirmation(t *testing.T) {
+	confirmPollInterval = time.Millisecond
+	results := map[string]string{
+		"getSignatureStatuses": `{"context":{"slot":7},"value":[{"slot":5,"confirmations":1,"err":null,"confirmationStatus":"processed"}]}`,
+		"getBlockHeight":       `100`,
+	}
+	client, node := newTestClient(t, results)
+	tx := solPTypes.Transaction{Message: solPTypes.NewMessage(solPTypes.NewMessageParam{
+		FeePayer:        common.PublicKeyFromString("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"),
+		RecentBlockhash: "9rAtxuhtKn8qagc3UtZFyhLrw5zgh6rLakLbHbQTGPFi",
+	})}
+
+	confirmation, err := client.WaitForConfirmation(context.Background(), "a", tx, rpc.CommitmentProcessed, 150)
+	assert.NoError(t, err)
+	assert.Equal(t, &Confirmation{Status: "processed", Slot: 5}, confirmation)
+
+	// confirmed is not reached by a processed status, the expired
+	// blockhash is only checked while the signature is unknown
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.WaitForConfirmation(ctx, "a", tx, rpc.CommitmentConfirmed, 50)
+	assert.Error(t, err)
+
+	node.set("getSignatureStatuses", `{"context":{"slot":9},"value":[{"slot":8,"confirmations":null,"err":{"InstructionError":[0,"Custom"]},"confirmationStatus":"confirmed"}]}`)
+	confirmation, err = client.WaitForConfirmation(context.Background(), "a", tx, rpc.CommitmentFinalized, 150)
+	assert.NoError(t, err)
+	assert.Equal(t, ConfirmationFailed, confirmation.Status)
+	assert.Equal(t, uint64(8), confirmation.Slot)
+
+	node.set("getSignatureStatuses", `{"context":{"slot":9},"value":[null]}`)
+	node.reset()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.WaitForConfirmation(ctx, "a", tx, rpc.CommitmentConfirmed, 150)
+	assert.Error(t, err)
+	assert.Equal(t, "getBlockHeight", node.requests()[1]["method"])
+	node.set("getBlockHeight", `151`)
+	confirmation, err = client.WaitForConfirmation(context.Background(), "a", tx, rpc.CommitmentConfirmed, 150)
+	assert.NoError(t, err)
+	assert.Equal(t, &Confirmation{Status: ConfirmationExpired}, confirmation)
+}
+
+func TestGetSubmitOptions(t *testing.T) {
+	opts, err := GetSubmitOptions(map[string]interface{}{
+		"skip_preflight":       true,
+		"preflight_commitment": "processed",
+		"max_retries":          3,
+		"wait_for":             "confirmed",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, true, opts.SkipPreflight)
+	assert.Equal(t, uint64(3), opts.MaxRetries)
+	assert.Equal(t, "confirmed", opts.WaitFor)
+
+	_, err = GetSubmitOptions(map[string]interface{}{"max_retries": "three"})
+	assert.Error(t, err)
+	_, err = ParseCommitment("recent")
+	assert.Error(t, err)
+}
+
+func TestRebroadcaster(t *testing.T) {
+	results := map[string]string{
+		"sendTransaction":      `"a"`,
+		"getSignatureStatuses": `{"context":{"slot":7},"value":[null]}`,
+		"isBlockhashValid":     `{"context":{"slot":7},"value":true}`,
+	}
+	client, node := newTestClient(t, results)
+	client.Mempool = NewMempool(1)
+	from := common.PublicKeyFromString("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH")
+	tx, err := solPTypes.NewTransaction(solPTypes.NewTransactionParam{
+		Message: solPTypes.NewMessage(solPTypes.NewMessageParam{
+			FeePayer:        from,
+			RecentBlockhash: "9rAtxuhtKn8qagc3UtZFyhLrw5zgh6rLakLbHbQTGPFi",
+		}),
+	})
+	assert.NoError(t, err)
+	assert.True(t, client.Mempool.Add("a", tx, nil))
+	assert.False(t, client.Mempool.Add("b", tx, nil))
+
+	r := NewRebroadcaster(client, time.Millisecond, time.Hour)
+	r.rebroadcast(context.Background())
+	assert.Equal(t, 1, len(node.requests()))
+	assert.Equal(t, "sendTransaction", node.requests()[0]["method"])
+	config := node.requests()[0]["params"].([]interface{})[1].(map[string]interface{})
+	assert.Equal(t, true, config["skipPreflight"])
+
+	// an expired transaction is dropped on refresh and no longer sent, an
+	// interval of 0 only refreshes
+	node.set("isBlockhashValid", `{"context":{"slot":8},"value":false}`)
+	node.reset()
+	r = NewRebroadcaster(client, 0, time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, r.Start(ctx))
+	assert.Equal(t, 0, len(client.Mempool.Transactions()))
+	for _, req := range node.requests() {
+		assert.NotEqual(t, "sendTransaction", req["method"])
+	}
+}
+
+func TestParseSimulationError(t *testing.T) {
+	from := common.PublicKeyFromString("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH")
+	to := common.PublicKeyFromString("95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV")
+	tx, err := solPTypes.NewTransaction(solPTypes.NewTransactionParam{
+		Message: solPTypes.NewMessage(solPTypes.NewMessageParam{
+			FeePayer:        from,
+			RecentBlockhash: "9rAtxuhtKn8qagc3UtZFyhLrw5zgh6rLakLbHbQTGPFi",
+			Instructions: []solPTypes.Instruction{
+				system.Transfer(system.TransferParam{From: from, To: to, Amount: 10}),
+			},
+		}),
+	})
+	assert.NoError(t, err)
+	rpcErr := func(data string) error {
+		var d interface{}
+		json.Unmarshal([]byte(data), &d)
+		return &rpc.JsonRpcError{Code: -32002, Message: "Transaction simulation failed", Data: d}
+	}
+
+	simErr := ParseSimulationError(rpcErr(`{"err":{"InstructionError":[0,{"Custom":1}]},"logs":["Program 11111111111111111111111111111111 invoke [1]","Transfer: insufficient lamports 0, need 10"]}`), tx)
+	assert.True(t, errors.Is(simErr, ErrInsufficientFunds))
+	assert.Equal(t, 0, *simErr.InstructionIndex)
+	assert.Equal(t, uint32(1), *simErr.CustomCode)
+	assert.Equal(t, 2, len(simErr.Logs))
+
+	simErr = ParseSimulationError(rpcErr(`{"err":{"InstructionError":[0,{"Custom":3}]},"logs":[]}`), tx)
+	assert.True(t, errors.Is(simErr, ErrInstructionFailure))
+	assert.Equal(t, uint32(3), *simErr.CustomCode)
+
+	simErr = ParseSimulationError(rpcErr(`{"err":{"InstructionError":[0,"InvalidAccountData"]},"logs":[]}`), tx)
+	assert.True(t, errors.Is(simErr, ErrInstructionFailure))
+	assert.Nil(t, simErr.CustomCode)
+
+	simErr = ParseSimulationError(rpcErr(`{"err":"BlockhashNotFound","logs":[]}`), tx)
+	assert.True(t, errors.Is(simErr, ErrBlockhashNotFound))
+	simErr = ParseSimulationError(rpcErr(`{"err":"AccountInUse","logs":null}`), tx)
+	assert.True(t, errors.Is(simErr, ErrAccountInUse))
+	simErr = ParseSimulationError(rpcErr(`{"err":"InsufficientFundsForFee","logs":null}`), tx)
+	assert.True(t, errors.Is(simErr, ErrInsufficientFunds))
+
+	assert.Nil(t, ParseSimulationError(&rpc.JsonRpcError{Code: -32003, Message: "Transaction signature verification failure"}, tx))
+	assert.Nil(t, ParseSimulationError(errors.New("connection refused"), tx))
+}
@@ -0,0 +1,107 @@
+package solanago
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	solPTypes "github.com/blocto/solana-go-sdk/types"
+)
+
+// confirmPollInterval is how often WaitForConfirmation polls
+// getSignatureStatuses.
+var confirmPollInterval = 500 * time.Millisecond
+
+const (
+	// ConfirmationFailed is the status of a transaction that landed
+	// with an error.
+	ConfirmationFailed = "failed"
+	// ConfirmationExpired is the status of a transaction that can no
+	// longer land.
+	ConfirmationExpired = "expired"
+)
+
+// Confirmation is the final status of a submitted transaction.
+type Confirmation struct {
+	Status string
+	Slot   uint64
+	Err    interface{}
+}
+
+// ParseCommitment returns the commitment level named s.
+func ParseCommitment(s string) (rpc.Commitment, error) {
+	switch c := rpc.Commitment(s); c {
+	case rpc.CommitmentProcessed, rpc.CommitmentConfirmed, rpc.CommitmentFinalized:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown commitment %q", s)
+}
+
+// commitmentRank orders commitment levels from weakest to strongest.
+var commitmentRank = map[rpc.Commitment]int{
+	rpc.CommitmentProcessed: 0,
+	rpc.CommitmentConfirmed: 1,
+	rpc.CommitmentFinalized: 2,
+}
+
+// WaitForConfirmation polls the status of the transaction with hash until
+// it reaches commitment or fails. It gives up once the transaction can no
+// longer land: the block height passed lastValidBlockHeight or, for
+// durable nonce transactions, the nonce account moved on.
+func (ec *Client) WaitForConfirmation(
+	ctx context.Context,
+	hash string,
+	tx solPTypes.Transaction,
+	commitment rpc.Commitment,
+	lastValidBlockHeight uint64,
+) (*Confirmation, error) {
+	ticker := time.NewTicker(confirmPollInterval)
+	defer ticker.Stop()
+	for {
+		statuses, err := ec.Rpc.GetSignatureStatuses(ctx, []string{hash})
+		if err != nil {
+			return nil, err
+		}
+		if len(statuses) > 0 && statuses[0] != nil {
+			status := statuses[0]
+			if status.Err != nil {
+				return &Confirmation{Status: ConfirmationFailed, Slot: status.Slot, Err: status.Err}, nil
+			}
+			if status.ConfirmationStatus != nil && commitmentRank[*status.ConfirmationStatus] >= commitmentRank[commitment] {
+				return &Confirmation{Status: string(*status.ConfirmationStatus), Slot: status.Slot}, nil
+			}
+		} else {
+			expired, err := ec.isExpired(ctx, tx, lastValidBlockHeight)
+			if err != nil {
+				return nil, err
+			}
+			if expired {
+				return &Confirmation{Status: ConfirmationExpired}, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// isExpired reports whether tx, not seen by the cluster yet, can no
+// longer land.
+func (ec *Client) isExpired(ctx context.Context, tx solPTypes.Transaction, lastValidBlockHeight uint64) (bool, error) {
+	if _, ok := advancedNonceAccount(tx); ok {
+		valid, err := ec.IsTransactionValid(ctx, tx)
+		return !valid, err
+	}
+	res, err := ec.Rpc.RpcClient.GetBlockHeight(ctx)
+	if err != nil {
+		return false, err
+	}
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.Result > lastValidBlockHeight, nil
+}
@@ -11,7 +11,9 @@ import (
 	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 )
 
 type ErrorResponse struct {
@@ -162,6 +164,121 @@ func (s *DirectClient) GetAccountInfoParsed(ctx context.Context, account string)
 	return res.Result.Value, nil
 }
 
+// GetMultipleAccountsParsed fetches the parsed state of accounts in one
+// request; accounts that do not exist are nil.
+func (s *DirectClient) GetMultipleAccountsParsed(ctx context.Context, accounts []string) ([]*GetAccountInfoParsedResponse, error) {
+	res := struct {
+		GeneralResponse
+		Result struct {
+			Context Context                         `json:"context"`
+			Value   []*GetAccountInfoParsedResponse `json:"value"`
+		} `json:"result"`
+	}{}
+	err := s.request(ctx, "getMultipleAccounts", []interface{}{accounts, map[string]interface{}{"encoding": "jsonParsed"}}, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != (ErrorResponse{}) {
+		return nil, errors.New(res.Error.Message)
+	}
+	if len(res.Result.Value) != len(accounts) {
+		return nil, fmt.Errorf("getMultipleAccounts returned %d of %d accounts", len(res.Result.Value), len(accounts))
+	}
+	return res.Result.Value, nil
+}
+
+// GetNonceAccount fetches a durable nonce account and returns
+// ErrInvalidNonceAccount when the account does not exist or is not an
+// initialized nonce account.
+func (s *DirectClient) GetNonceAccount(ctx context.Context, account string) (stypes.NonceAccount, error) {
+	acc, err := s.GetAccountInfoParsed(ctx, account)
+	if err != nil {
+		return stypes.NonceAccount{}, err
+	}
+	if acc.Owner == "" {
+		return stypes.NonceAccount{}, fmt.Errorf("%w: %s not found", ErrInvalidNonceAccount, account)
+	}
+	return ToNonceAccount(acc)
+}
+
+// ToNonceAccount converts parsed account info into the state of an
+// initialized nonce account.
+func ToNonceAccount(acc GetAccountInfoParsedResponse) (stypes.NonceAccount, error) {
+	if acc.Data.Program != "nonce" {
+		return stypes.NonceAccount{}, fmt.Errorf("%w: account is owned by %s", ErrInvalidNonceAccount, acc.Owner)
+	}
+	if acc.Data.Parsed.Type != "initialized" {
+		return stypes.NonceAccount{}, fmt.Errorf("%w: account is not initialized", ErrInvalidNonceAccount)
+	}
+	info := acc.Data.Parsed.Info
+	return stypes.NonceAccount{
+		Authority:            info.Authority,
+		BlockHash:            info.BlockHash,
+		LamportsPerSignature: ValueToBaseAmount(info.FeeCalculator.LamportsPerSignature),
+		Lamports:             acc.Lamports,
+	}, nil
+}
+
+// GetStakeActivation returns the activation state of a stake account as
+// reported by the node.
+func (s *DirectClient) GetStakeActivation(ctx context.Context, account string) (stypes.StakeActivation, error) {
+	res := struct {
+		GeneralResponse
+		Result stypes.StakeActivation `json:"result"`
+	}{}
+	err := s.request(ctx, "getStakeActivation", []interface{}{account}, &res)
+	if err != nil {
+		return stypes.StakeActivation{}, err
+	}
+	if res.Error != (ErrorResponse{}) {
+		return stypes.StakeActivation{}, errors.New(res.Error.Message)
+	}
+	return res.Result, nil
+}
+
+// ToStakeActivation estimates the activation state of a stake account in
+// epoch from its delegation, for nodes that no longer serve
+// getStakeActivation. Warmup and cooldown are not taken into account, the
+// whole delegation is activating or deactivating until the epoch after it
+// was (de)activated.
+func ToStakeActivation(acc GetAccountInfoParsedResponse, epoch uint64) stypes.StakeActivation {
+	info := acc.Data.Parsed.Info
+	var reserve uint64
+	if acc.Lamports > ValueToBaseAmount(info.Meta.RentExemptReserve) {
+		reserve = ValueToBaseAmount(info.Meta.RentExemptReserve)
+	} else {
+		reserve = acc.Lamports
+	}
+	activation := stypes.StakeActivation{State: "inactive", Inactive: acc.Lamports - reserve}
+	if acc.Data.Parsed.Type != "delegated" {
+		return activation
+	}
+	delegation := info.Stake.Delegation
+	stake := ValueToBaseAmount(delegation.Stake)
+	activationEpoch, _ := strconv.ParseUint(delegation.ActivationEpoch, 10, 64)
+	deactivationEpoch, err := strconv.ParseUint(delegation.DeactivationEpoch, 10, 64)
+	if err != nil {
+		deactivationEpoch = math.MaxUint64
+	}
+	switch {
+	case activationEpoch == deactivationEpoch, deactivationEpoch < epoch:
+		return activation
+	case epoch <= activationEpoch:
+		activation.State = "activating"
+		return activation
+	case deactivationEpoch == epoch:
+		activation.State = "deactivating"
+	default:
+		activation.State = "active"
+	}
+	if stake > activation.Inactive {
+		stake = activation.Inactive
+	}
+	activation.Active = stake
+	activation.Inactive -= stake
+	return activation
+}
+
 func (s *DirectClient) GetConfirmedBlockParsed(ctx context.Context, slot uint64) (stypes.GetConfirmBlockParsedResponse, error) {
 	res := struct {
 		GeneralResponse
@@ -212,6 +329,12 @@ func (s *DirectClient) GetTokenAccountByOwner(ctx context.Context, account strin
 }
 
 func (s *DirectClient) GetTokenAccountsByOwner(ctx context.Context, account string) ([]stypes.Accounts, error) {
+	return s.GetTokenAccountsByOwnerWithCommitment(ctx, account, "")
+}
+
+// GetTokenAccountsByOwnerWithCommitment lists the token accounts of
+// account at commitment, the node default if empty.
+func (s *DirectClient) GetTokenAccountsByOwnerWithCommitment(ctx context.Context, account string, commitment string) ([]stypes.Accounts, error) {
 	res := struct {
 		GeneralResponse
 		Result struct {
@@ -219,17 +342,49 @@ func (s *DirectClient) GetTokenAccountsByOwner(ctx context.Context, account stri
 			Value   []stypes.Accounts `json:"value"`
 		} `json:"result"`
 	}{}
+	config := map[string]interface{}{
+		"encoding": "jsonParsed",
+	}
+	if commitment != "" {
+		config["commitment"] = commitment
+	}
 	params := []interface{}{account,
 		map[string]interface{}{"programId": common.TokenProgramID.ToBase58()},
+		config}
+	log.Printf("GetTokenAccountsByOwner before s.request for %s", account)
+	err := s.request(ctx, "getTokenAccountsByOwner", params, &res)
+	log.Printf("GetTokenAccountsByOwner after s.request")
+	if err != nil {
+		return []stypes.Accounts{}, err
+	}
+	if res.Error != (ErrorResponse{}) {
+		return []stypes.Accounts{}, errors.New(res.Error.Message)
+	}
+	return res.Result.Value, nil
+}
+
+// GetTokenAccountsByMint returns every token account of mint owned by
+// account.
+func (s *DirectClient) GetTokenAccountsByMint(ctx context.Context, account string, mint string) ([]stypes.Accounts, error) {
+	res := struct {
+		GeneralResponse
+		Result struct {
+			Context Context           `json:"context"`
+			Value   []stypes.Accounts `json:"value"`
+		} `json:"result"`
+	}{}
+	params := []interface{}{account,
+		map[string]interface{}{"mint": mint},
 		map[string]interface{}{
 			"encoding": "jsonParsed",
 		}}
-	log.Printf("GetTokenAccountsByOwner before s.request for %s", account)
 	err := s.request(ctx, "getTokenAccountsByOwner", params, &res)
-	log.Printf("GetTokenAccountsByOwner after s.request")
 	if err != nil {
 		return []stypes.Accounts{}, err
 	}
+	if res.Error != (ErrorResponse{}) {
+		return []stypes.Accounts{}, errors.New(res.Error.Message)
+	}
 	return res.Result.Value, nil
 }
 
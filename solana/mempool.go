@@ -0,0 +1,215 @@
+package solanago
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	solPTypes "github.com/blocto/solana-go-sdk/types"
+	RosettaTypes "github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/imerkle/rosetta-solana-go/solana/parse"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+)
+
+// maxSignatureStatuses is the most signatures getSignatureStatuses
+// accepts in one request.
+const maxSignatureStatuses = 256
+
+// PendingTransaction is a transaction accepted by /construction/submit
+// that has not been confirmed yet.
+type PendingTransaction struct {
+	Hash        string
+	Transaction solPTypes.Transaction
+	Operations  []*RosettaTypes.Operation
+	Submitted   time.Time
+}
+
+// DefaultMempoolSize is the size of the mempool of a new Client.
+const DefaultMempoolSize = 1024
+
+// Mempool tracks the transactions submitted through this server until
+// they are confirmed or their blockhash or nonce is no longer valid.
+// It holds at most size transactions.
+type Mempool struct {
+	mu      sync.Mutex
+	size    int
+	pending map[string]*PendingTransaction
+}
+
+func NewMempool(size int) *Mempool {
+	return &Mempool{size: size, pending: make(map[string]*PendingTransaction)}
+}
+
+// Add starts tracking tx under its signature hash, with its stake
+// accounts keyed by stakeWithdrawers. It reports false when the mempool
+// is full.
+func (m *Mempool) Add(hash string, tx solPTypes.Transaction, stakeWithdrawers map[string]string) bool {
+	var operations []*RosettaTypes.Operation
+	if parsedTx, err := parse.ToParsedTransaction(tx); err == nil {
+		operations = GetRosOperationsFromTxWithMeta(parsedTx, stypes.TransactionMeta{}, stakeWithdrawers, "")
+		// pending operations have no status yet
+		for _, op := range operations {
+			op.Status = nil
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.pending[hash]; ok {
+		return true
+	}
+	if len(m.pending) >= m.size {
+		return false
+	}
+	m.pending[hash] = &PendingTransaction{
+		Hash:        hash,
+		Transaction: tx,
+		Operations:  operations,
+		Submitted:   time.Now(),
+	}
+	return true
+}
+
+// AddToMempool tracks a submitted transaction with its stake accounts
+// keyed by their withdrawers, as /block lists them once it lands. It
+// reports false when the mempool is full.
+func (ec *Client) AddToMempool(ctx context.Context, hash string, tx solPTypes.Transaction) bool {
+	var withdrawers map[string]string
+	if parsedTx, err := parse.ToParsedTransaction(tx); err == nil {
+		// accounts whose withdrawer cannot be fetched keep their own address
+		withdrawers, _ = ec.StakeWithdrawers(ctx, parsedTx)
+	}
+	return ec.Mempool.Add(hash, tx, withdrawers)
+}
+
+// Remove stops tracking the transaction with hash.
+func (m *Mempool) Remove(hash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, hash)
+}
+
+// Get returns the pending transaction with hash.
+func (m *Mempool) Get(hash string) (*PendingTransaction, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx, ok := m.pending[hash]
+	return tx, ok
+}
+
+// Transactions returns the pending transactions, oldest first.
+func (m *Mempool) Transactions() []*PendingTransaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	txs := make([]*PendingTransaction, 0, len(m.pending))
+	for _, tx := range m.pending {
+		txs = append(txs, tx)
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Submitted.Equal(txs[j].Submitted) {
+			return txs[i].Hash < txs[j].Hash
+		}
+		return txs[i].Submitted.Before(txs[j].Submitted)
+	})
+	return txs
+}
+
+// RefreshMempool drops the pending transactions that are confirmed,
+// failed or can no longer land. A transaction whose state cannot be
+// fetched is kept until a later refresh; the first such error is
+// returned once every other transaction is checked.
+func (ec *Client) RefreshMempool(ctx context.Context) error {
+	var firstErr error
+	keep := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	// transactions of a batch payout usually share their blockhash
+	validBlockhashes := make(map[string]bool)
+	txs := ec.Mempool.Transactions()
+	for start := 0; start < len(txs); start += maxSignatureStatuses {
+		end := start + maxSignatureStatuses
+		if end > len(txs) {
+			end = len(txs)
+		}
+		batch := txs[start:end]
+		hashes := make([]string, len(batch))
+		for i, tx := range batch {
+			hashes[i] = tx.Hash
+		}
+		statuses, err := ec.Rpc.GetSignatureStatuses(ctx, hashes)
+		if err != nil {
+			keep(err)
+			continue
+		}
+		for i, tx := range batch {
+			if i < len(statuses) && isSettled(statuses[i]) {
+				ec.Mempool.Remove(tx.Hash)
+				continue
+			}
+			valid, cached := validBlockhashes[tx.Transaction.Message.RecentBlockHash]
+			if _, nonce := advancedNonceAccount(tx.Transaction); nonce || !cached {
+				valid, err = ec.IsTransactionValid(ctx, tx.Transaction)
+				if err != nil {
+					keep(err)
+					continue
+				}
+				if !nonce {
+					validBlockhashes[tx.Transaction.Message.RecentBlockHash] = valid
+				}
+			}
+			if !valid {
+				ec.Mempool.Remove(tx.Hash)
+			}
+		}
+	}
+	return firstErr
+}
+
+// isSettled reports whether a signature status is final for the mempool:
+// the transaction failed or was confirmed by the cluster.
+func isSettled(status *rpc.SignatureStatus) bool {
+	if status == nil {
+		return false
+	}
+	if status.Err != nil {
+		return true
+	}
+	return status.ConfirmationStatus != nil && *status.ConfirmationStatus != rpc.CommitmentProcessed
+}
+
+// IsTransactionValid reports whether tx can still land: its recent
+// blockhash is valid or, for durable nonce transactions, the nonce
+// account still holds the nonce it was signed with.
+func (ec *Client) IsTransactionValid(ctx context.Context, tx solPTypes.Transaction) (bool, error) {
+	if nonceAccount, ok := advancedNonceAccount(tx); ok {
+		nonce, err := ec.directClient.GetNonceAccount(ctx, nonceAccount)
+		if errors.Is(err, ErrInvalidNonceAccount) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return nonce.BlockHash == tx.Message.RecentBlockHash, nil
+	}
+	return ec.Rpc.IsBlockhashValid(ctx, tx.Message.RecentBlockHash)
+}
+
+// advancedNonceAccount returns the nonce account advanced by the first
+// instruction of a durable nonce transaction.
+func advancedNonceAccount(tx solPTypes.Transaction) (string, bool) {
+	ins := tx.Message.DecompileInstructions()
+	if len(ins) == 0 || ins[0].ProgramID != common.SystemProgramID || len(ins[0].Accounts) == 0 || len(ins[0].Data) < 4 {
+		return "", false
+	}
+	if binary.LittleEndian.Uint32(ins[0].Data) != uint32(system.InstructionAdvanceNonceAccount) {
+		return "", false
+	}
+	return ins[0].Accounts[0].PubKey.ToBase58(), true
+}
@@ -0,0 +1,102 @@
+package operations
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/blocto/solana-go-sdk/common"
+	solPTypes "github.com/blocto/solana-go-sdk/types"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+	"github.com/mr-tron/base58"
+	"log"
+)
+
+// GenericAccountMeta is an account of a Generic__Instruction, in the
+// order the program expects it.
+type GenericAccountMeta struct {
+	Pubkey     string `json:"pubkey"`
+	IsSigner   bool   `json:"is_signer,omitempty"`
+	IsWritable bool   `json:"is_writable,omitempty"`
+}
+
+// GenericOperationMetadata is a raw instruction of a program that has no
+// operation types of its own. Data is base58 unless Encoding is base64.
+type GenericOperationMetadata struct {
+	ProgramID string               `json:"program_id"`
+	Accounts  []GenericAccountMeta `json:"accounts,omitempty"`
+	Data      string               `json:"data,omitempty"`
+	Encoding  string               `json:"encoding,omitempty"`
+}
+
+func (x *GenericOperationMetadata) SetMeta(op *types.Operation) error {
+	return decodeMetadata(op.Metadata, x)
+}
+
+// Validate checks the program, accounts and data of the instruction.
+func (x *GenericOperationMetadata) Validate(opType string) error {
+	if opType != stypes.Generic__Instruction {
+		return unsupported(opType)
+	}
+	if err := requireFields("program_id", x.ProgramID); err != nil {
+		return err
+	}
+	if err := checkAddresses("program_id", x.ProgramID); err != nil {
+		return err
+	}
+	for i, account := range x.Accounts {
+		if !IsAddress(account.Pubkey) {
+			return FieldErrorf(fmt.Sprintf("metadata.accounts[%d].pubkey", i), "%s is not a valid public key", account.Pubkey)
+		}
+	}
+	if x.Encoding != "" && x.Encoding != "base58" && x.Encoding != "base64" {
+		return FieldErrorf("metadata.encoding", "%s is not base58 or base64", x.Encoding)
+	}
+	if _, err := x.decodeData(); err != nil {
+		return FieldErrorf("metadata.data", "is not %s: %v", x.encoding(), err)
+	}
+	return nil
+}
+
+// IsSigner reports whether address signs the instruction.
+func (x *GenericOperationMetadata) IsSigner(address string) bool {
+	for _, account := range x.Accounts {
+		if account.IsSigner && account.Pubkey == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *GenericOperationMetadata) encoding() string {
+	if x.Encoding == "" {
+		return "base58"
+	}
+	return x.Encoding
+}
+
+func (x *GenericOperationMetadata) decodeData() ([]byte, error) {
+	if x.Data == "" {
+		return []byte{}, nil
+	}
+	if x.encoding() == "base64" {
+		return base64.StdEncoding.DecodeString(x.Data)
+	}
+	return base58.Decode(x.Data)
+}
+
+func (x *GenericOperationMetadata) ToInstructions(opType string) []solPTypes.Instruction {
+	var ins []solPTypes.Instruction
+	switch opType {
+	case stypes.Generic__Instruction:
+		var accounts []solPTypes.AccountMeta
+		for _, account := range x.Accounts {
+			accounts = append(accounts, solPTypes.AccountMeta{PubKey: p(account.Pubkey), IsSigner: account.IsSigner, IsWritable: account.IsWritable})
+		}
+		data, _ := x.decodeData()
+		ins = append(ins, solPTypes.Instruction{ProgramID: common.PublicKeyFromString(x.ProgramID), Accounts: accounts, Data: data})
+		break
+	default:
+		log.Printf("ERROR: unknown opType='%v'", opType)
+	}
+	return ins
+}
@@ -0,0 +1,60 @@
+package operations
+
+import (
+	"fmt"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/memo"
+	solPTypes "github.com/blocto/solana-go-sdk/types"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	solanago "github.com/imerkle/rosetta-solana-go/solana"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+	"log"
+)
+
+type MemoOperationMetadata struct {
+	Memo    string   `json:"memo"`
+	Signers []string `json:"signers,omitempty"`
+}
+
+func (x *MemoOperationMetadata) SetMeta(op *types.Operation) error {
+	if err := decodeMetadata(op.Metadata, x); err != nil {
+		return err
+	}
+	if op.Account != nil && op.Account.Address != "" && !solanago.Contains(x.Signers, op.Account.Address) {
+		x.Signers = append([]string{op.Account.Address}, x.Signers...)
+	}
+	return nil
+}
+
+// Validate checks that the memo is set and that all signers are public
+// keys.
+func (x *MemoOperationMetadata) Validate(opType string) error {
+	if opType != stypes.Memo__Memo {
+		return unsupported(opType)
+	}
+	if err := requireFields("memo", x.Memo); err != nil {
+		return err
+	}
+	for i, signer := range x.Signers {
+		if !IsAddress(signer) {
+			return FieldErrorf(fmt.Sprintf("metadata.signers[%d]", i), "%s is not a valid public key", signer)
+		}
+	}
+	return nil
+}
+
+func (x *MemoOperationMetadata) ToInstructions(opType string) []solPTypes.Instruction {
+	var ins []solPTypes.Instruction
+	switch opType {
+	case stypes.Memo__Memo:
+		var signers []common.PublicKey
+		for _, signer := range x.Signers {
+			signers = append(signers, p(signer))
+		}
+		ins = append(ins, memo.BuildMemo(memo.BuildMemoParam{SignerPubkeys: signers, Memo: []byte(x.Memo)}))
+		break
+	default:
+		log.Printf("ERROR: unknown opType='%v'", opType)
+	}
+	return ins
+}
@@ -1,7 +1,6 @@
 package operations
 
 import (
-	"encoding/json"
 	"github.com/blocto/solana-go-sdk/common"
 	token "github.com/blocto/solana-go-sdk/program/associated_token_account"
 	solPTypes "github.com/blocto/solana-go-sdk/types"
@@ -15,12 +14,31 @@ type SplAssociatedTokenAccountOperationMetadata struct {
 	Mint   string `json:"mint,omitempty"`
 }
 
-func (x *SplAssociatedTokenAccountOperationMetadata) SetMeta(op *types.Operation) {
-	jsonString, _ := json.Marshal(op.Metadata)
+func (x *SplAssociatedTokenAccountOperationMetadata) SetMeta(op *types.Operation) error {
 	if x.Source == "" {
 		x.Source = op.Account.Address
 	}
-	json.Unmarshal(jsonString, &x)
+	return decodeMetadata(op.Metadata, x)
+}
+
+// Validate checks that the wallet and mint are set and that all
+// accounts are public keys.
+func (x *SplAssociatedTokenAccountOperationMetadata) Validate(opType string) error {
+	if opType != stypes.SplAssociatedTokenAccount__Create {
+		return unsupported(opType)
+	}
+	if err := requireFields("wallet", x.Wallet, "mint", x.Mint); err != nil {
+		return err
+	}
+	return checkAddresses("source", x.Source, "wallet", x.Wallet, "mint", x.Mint)
+}
+
+// createIdempotentAssociatedAccount returns the associated token account of
+// owner for mint and the instruction that creates it unless it already exists.
+func createIdempotentAssociatedAccount(funder common.PublicKey, owner common.PublicKey, mint common.PublicKey) (common.PublicKey, solPTypes.Instruction) {
+	assosiatedAccount, _, _ := common.FindAssociatedTokenAddress(owner, mint)
+	in := token.CreateIdempotent(token.CreateIdempotentParam{Funder: funder, Owner: owner, Mint: mint, AssociatedTokenAccount: assosiatedAccount})
+	return assosiatedAccount, in
 }
 
 func (x *SplAssociatedTokenAccountOperationMetadata) ToInstructions(opType string) []solPTypes.Instruction {
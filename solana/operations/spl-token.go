@@ -1,7 +1,6 @@
 package operations
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/blocto/solana-go-sdk/common"
 	"github.com/blocto/solana-go-sdk/program/associated_token_account"
@@ -27,18 +26,27 @@ type SplTokenOperationMetadata struct {
 
 	SourceToken      string `json:"source_token,omitempty"`
 	DestinationToken string `json:"destination_token,omitempty"`
+
+	// Signers sign for a multisig authority.
+	Signers []string `json:"signers,omitempty"`
 }
 
-func (x *SplTokenOperationMetadata) SetMeta(op *types.Operation, splTokenAccsMap map[string]stypes.SplAccounts) {
-	jsonString, _ := json.Marshal(op.Metadata)
+// maxMultisigSigners is the most signers an SPL multisig account has.
+const maxMultisigSigners = 11
+
+func (x *SplTokenOperationMetadata) SetMeta(op *types.Operation, splTokenAccsMap map[string]stypes.SplAccounts) error {
 	if op.Amount != nil && x.Amount == 0 {
 		x.Amount = solanago.ValueToBaseAmount(op.Amount.Value)
 	}
 	if x.Source == "" {
-		x.Source = op.Account.Address
+		x.Source = solanago.SubAccountAddress(op.Account)
+	}
+	// tokens are minted to the sub-account
+	if x.Destination == "" && op.Type == stypes.SplToken__MintTo {
+		x.Destination = solanago.SubAccountAddress(op.Account)
 	}
 	if x.Authority == "" {
-		x.Authority = x.Source
+		x.Authority = op.Account.Address
 	}
 	if op.Amount != nil && x.Mint == "" {
 		x.Mint = op.Amount.Currency.Symbol
@@ -51,7 +59,66 @@ func (x *SplTokenOperationMetadata) SetMeta(op *types.Operation, splTokenAccsMap
 		x.DestinationToken = w.Destination
 	}
 
-	json.Unmarshal(jsonString, &x)
+	return decodeMetadata(op.Metadata, x)
+}
+
+// Validate checks the accounts of the operation and that the fields
+// opType needs are set.
+func (x *SplTokenOperationMetadata) Validate(opType string) error {
+	if err := checkAddresses("source", x.Source, "destination", x.Destination, "authority", x.Authority, "source_token", x.SourceToken, "destination_token", x.DestinationToken); err != nil {
+		return err
+	}
+	for i, signer := range x.Signers {
+		if !IsAddress(signer) {
+			return FieldErrorf(fmt.Sprintf("metadata.signers[%d]", i), "%s is not a valid public key", signer)
+		}
+	}
+	if len(x.Signers) > maxMultisigSigners {
+		return FieldErrorf("metadata.signers", "has %d signers, a multisig has at most %d", len(x.Signers), maxMultisigSigners)
+	}
+	switch opType {
+	case stypes.SplToken__CreateAccount, stypes.SplToken__Transfer, stypes.SplToken__TransferChecked, stypes.SplToken__TransferNew, stypes.SplToken__TransferWithSystem, stypes.SplToken__MintTo:
+		if err := requireFields("destination", x.Destination, "mint", x.Mint); err != nil {
+			return err
+		}
+		if !IsAddress(x.Mint) {
+			return FieldErrorf("metadata.mint", "%s is not a valid public key", x.Mint)
+		}
+	case stypes.SplToken__Burn:
+		if err := requireFields("mint", x.Mint); err != nil {
+			return err
+		}
+		if !IsAddress(x.Mint) {
+			return FieldErrorf("metadata.mint", "%s is not a valid public key", x.Mint)
+		}
+	case stypes.SplToken__WrapSol, stypes.SplToken__UnwrapSol:
+	default:
+		return unsupported(opType)
+	}
+	// the authority of these is the owner of a new account or a system account
+	if len(x.Signers) > 0 && (opType == stypes.SplToken__CreateAccount || opType == stypes.SplToken__WrapSol) {
+		return FieldErrorf("metadata.signers", "are not used by %s", opType)
+	}
+	return nil
+}
+
+// multisigSigners returns the signers of a multisig authority, none if the
+// authority signs itself.
+func (x *SplTokenOperationMetadata) multisigSigners() []common.PublicKey {
+	signers := []common.PublicKey{}
+	for _, signer := range x.Signers {
+		signers = append(signers, p(signer))
+	}
+	return signers
+}
+
+// funder pays for the token accounts the operation creates. A multisig
+// authority cannot sign, so its first signer pays.
+func (x *SplTokenOperationMetadata) funder() common.PublicKey {
+	if len(x.Signers) > 0 {
+		return p(x.Signers[0])
+	}
+	return p(x.Authority)
 }
 
 func (x *SplTokenOperationMetadata) ToInstructions(opType string) []solPTypes.Instruction {
@@ -80,63 +147,77 @@ func (x *SplTokenOperationMetadata) ToInstructions(opType string) []solPTypes.In
 		//	case solanago.SplToken__Revoke:
 		//		ins = append(ins, tokenprog.Revoke(p(x.Source), p(x.Authority), []common.PublicKey{}))
 		//		break
-		//	case solanago.SplToken_MintTo:
-		//		ins = append(ins, tokenprog.MintTo(p(x.Mint), p(x.Source), p(x.Authority), []common.PublicKey{}, x.Amount))
-		//		break
-		//	case solanago.SplToken_Burn:
-		//		ins = append(ins, tokenprog.Burn(p(x.Source), p(x.Mint), p(x.Authority), []common.PublicKey{}, x.Amount))
-		//		break
+	// the checked instructions name the mint and its decimals, so the
+	// currency is known from the transaction alone
+	case stypes.SplToken__MintTo:
+		ins = append(ins, token.MintToChecked(token.MintToCheckedParam{Mint: p(x.Mint), To: p(x.Destination), Auth: p(x.Authority), Signers: x.multisigSigners(), Amount: x.Amount, Decimals: x.Decimals}))
+		break
+	case stypes.SplToken__Burn:
+		ins = append(ins, token.BurnChecked(token.BurnCheckedParam{Account: p(x.Source), Mint: p(x.Mint), Auth: p(x.Authority), Signers: x.multisigSigners(), Amount: x.Amount, Decimals: x.Decimals}))
+		break
 		//	case solanago.SplToken_CloseAccount:
 		//		ins = append(ins, tokenprog.CloseAccount(p(x.Source), p(x.Destination), p(x.Authority), []common.PublicKey{}))
 		//		break
 		//	case solanago.SplToken_FreezeAccount:
 		//		ins = append(ins, tokenprog.ThawAccount(p(x.Source), p(x.Mint), p(x.Authority), []common.PublicKey{}))
 		//		break
-	case stypes.SplToken__Transfer:
-		param := token.TransferParam{
-			From:    p(x.Source),
-			To:      p(x.Destination),
-			Auth:    p(x.Authority),
-			Signers: []common.PublicKey{},
-			Amount:  x.Amount}
-		ins = append(ins, token.Transfer(param))
-		break
-	case stypes.SplToken__TransferChecked:
+	case stypes.SplToken__Transfer, stypes.SplToken__TransferChecked:
 		param := token.TransferCheckedParam{
 			From:     p(x.Source),
 			To:       p(x.Destination),
 			Mint:     p(x.Mint),
 			Auth:     p(x.Authority),
-			Signers:  []common.PublicKey{},
+			Signers:  x.multisigSigners(),
 			Amount:   x.Amount,
 			Decimals: x.Decimals}
 		ins = append(ins, token.TransferChecked(param))
 		break
 	case stypes.SplToken__TransferNew:
 		assosiatedAccount, _, _ := common.FindAssociatedTokenAddress(p(x.Destination), p(x.Mint))
-		ins_create_assoc := assotokenprog.CreateAssociatedTokenAccount(assotokenprog.CreateAssociatedTokenAccountParam{Funder: p(x.Authority), Owner: p(x.Destination), Mint: p(x.Mint), AssociatedTokenAccount: assosiatedAccount})
+		ins_create_assoc := assotokenprog.CreateAssociatedTokenAccount(assotokenprog.CreateAssociatedTokenAccountParam{Funder: x.funder(), Owner: p(x.Destination), Mint: p(x.Mint), AssociatedTokenAccount: assosiatedAccount})
 		account := ins_create_assoc.Accounts[1].PubKey.ToBase58()
 		ins = append(ins, ins_create_assoc)
-		ins = append(ins, tokenprog.TransferChecked(tokenprog.TransferCheckedParam{From: p(x.Source), To: p(account), Mint: p(x.Mint), Auth: p(x.Authority), Signers: []common.PublicKey{}, Amount: x.Amount, Decimals: x.Decimals}))
+		ins = append(ins, tokenprog.TransferChecked(tokenprog.TransferCheckedParam{From: p(x.Source), To: p(account), Mint: p(x.Mint), Auth: p(x.Authority), Signers: x.multisigSigners(), Amount: x.Amount, Decimals: x.Decimals}))
 		break
 	case stypes.SplToken__TransferWithSystem:
 		source := x.SourceToken
 		destination := x.DestinationToken
 		if x.SourceToken == "" {
-			assosiatedAccount, _, _ := common.FindAssociatedTokenAddress(p(source), p(x.Mint))
-			param := associated_token_account.CreateIdempotentParam{Funder: p(x.Authority), Owner: p(source), Mint: p(x.Mint), AssociatedTokenAccount: assosiatedAccount}
+			assosiatedAccount, _, _ := common.FindAssociatedTokenAddress(p(x.Source), p(x.Mint))
+			param := associated_token_account.CreateIdempotentParam{Funder: x.funder(), Owner: p(x.Source), Mint: p(x.Mint), AssociatedTokenAccount: assosiatedAccount}
 			in := associated_token_account.CreateIdempotent(param)
 			source = in.Accounts[1].PubKey.ToBase58()
 			ins = append(ins, in)
 		}
 		if x.DestinationToken == "" {
 			assosiatedAccount, _, _ := common.FindAssociatedTokenAddress(p(x.Destination), p(x.Mint))
-			param := associated_token_account.CreateIdempotentParam{Funder: p(x.Authority), Owner: p(x.Destination), Mint: p(x.Mint), AssociatedTokenAccount: assosiatedAccount}
+			param := associated_token_account.CreateIdempotentParam{Funder: x.funder(), Owner: p(x.Destination), Mint: p(x.Mint), AssociatedTokenAccount: assosiatedAccount}
 			in := associated_token_account.CreateIdempotent(param)
 			destination = in.Accounts[1].PubKey.ToBase58()
 			ins = append(ins, in)
 		}
-		ins = append(ins, tokenprog.TransferChecked(tokenprog.TransferCheckedParam{From: p(x.Source), To: p(destination), Mint: p(x.Mint), Auth: p(x.Authority), Signers: []common.PublicKey{}, Amount: x.Amount, Decimals: x.Decimals}))
+		ins = append(ins, tokenprog.TransferChecked(tokenprog.TransferCheckedParam{From: p(source), To: p(destination), Mint: p(x.Mint), Auth: p(x.Authority), Signers: x.multisigSigners(), Amount: x.Amount, Decimals: x.Decimals}))
+		break
+	case stypes.SplToken__WrapSol:
+		owner := x.Destination
+		if owner == "" {
+			owner = x.Source
+		}
+		wrappedAccount, in := createIdempotentAssociatedAccount(p(x.Authority), p(owner), p(stypes.NativeMint))
+		ins = append(ins, in)
+		ins = append(ins, system.Transfer(system.TransferParam{From: p(x.Source), To: wrappedAccount, Amount: x.Amount}))
+		ins = append(ins, token.SyncNative(token.SyncNativeParam{Account: wrappedAccount}))
+		break
+	case stypes.SplToken__UnwrapSol:
+		wrappedAccount := p(x.SourceToken)
+		if x.SourceToken == "" {
+			wrappedAccount, _, _ = common.FindAssociatedTokenAddress(p(x.Source), p(stypes.NativeMint))
+		}
+		destination := x.Destination
+		if destination == "" {
+			destination = x.Source
+		}
+		ins = append(ins, token.CloseAccount(token.CloseAccountParam{Account: wrappedAccount, Auth: p(x.Authority), Signers: x.multisigSigners(), To: p(destination)}))
 		break
 	default:
 		log.Printf("ERROR: unknown opType='%v'", opType)
@@ -1,8 +1,9 @@
 package operations
 
 import (
-	"encoding/json"
+	"fmt"
 	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/pkg/bincode"
 	"github.com/blocto/solana-go-sdk/program/stake"
 	"github.com/blocto/solana-go-sdk/program/stakeprog"
 	"github.com/blocto/solana-go-sdk/program/system"
@@ -14,31 +15,41 @@ import (
 )
 
 type StakeOperationMetadata struct {
-	Source                 string `json:"source,omitempty"`
-	Stake                  string `json:"stake,omitempty"`
-	Lamports               uint64 `json:"lamports,omitempty"`
-	Staker                 string `json:"staker,omitempty"`
-	Withdrawer             string `json:"withdrawer,omitempty"`
-	WithdrawDestination    string `json:"withdrawDestination,omitempty"`
-	LockupUnixTimestamp    int64  `json:"lockupUnixTimestamp,omitempty"`
-	LockupEpoch            uint64 `json:"lockupEpoch,omitempty"`
-	LockupCustodian        string `json:"lockupCustodian,omitempty"`
-	VoteAccount            string `json:"voteAccount,omitempty"`
-	MergeDestination       string `json:"mergeDestination,omitempty"`
-	SplitDestination       string `json:"splitDestination,omitempty"`
-	Authority              string `json:"authority,omitempty"`
-	NewAuthority           string `json:"newAuthority,omitempty"`
-	StakeAuthorizationType uint32 `json:"stakeAuthorizationType,omitempty"`
-	FeePayer               string `json:"feePayer,omitempty"`
-	MicroLamportsUnitPrice uint64 `json:"micro_lamports_unit_price,omitempty"`
-}
-
-func (x *StakeOperationMetadata) SetMeta(op *types.Operation, fee stypes.PriorityFee) {
-	jsonString, _ := json.Marshal(op.Metadata)
-	json.Unmarshal(jsonString, &x)
+	Source                 string  `json:"source,omitempty"`
+	Stake                  string  `json:"stake,omitempty"`
+	Lamports               uint64  `json:"lamports,omitempty"`
+	Staker                 string  `json:"staker,omitempty"`
+	Withdrawer             string  `json:"withdrawer,omitempty"`
+	WithdrawDestination    string  `json:"withdrawDestination,omitempty"`
+	LockupUnixTimestamp    *int64  `json:"lockupUnixTimestamp,omitempty"`
+	LockupEpoch            *uint64 `json:"lockupEpoch,omitempty"`
+	LockupCustodian        string  `json:"lockupCustodian,omitempty"`
+	VoteAccount            string  `json:"voteAccount,omitempty"`
+	MergeDestination       string  `json:"mergeDestination,omitempty"`
+	SplitDestination       string  `json:"splitDestination,omitempty"`
+	SplitSeed              string  `json:"splitSeed,omitempty"`
+	RentExemptLamports     uint64  `json:"rentExemptLamports,omitempty"`
+	Authority              string  `json:"authority,omitempty"`
+	AuthorityBase          string  `json:"authorityBase,omitempty"`
+	AuthoritySeed          string  `json:"authoritySeed,omitempty"`
+	AuthorityOwner         string  `json:"authorityOwner,omitempty"`
+	NewAuthority           string  `json:"newAuthority,omitempty"`
+	RedelegateDestination  string  `json:"redelegateDestination,omitempty"`
+	StakeAuthorizationType uint32  `json:"stakeAuthorizationType,omitempty"`
+	FeePayer               string  `json:"feePayer,omitempty"`
+	MicroLamportsUnitPrice uint64  `json:"micro_lamports_unit_price,omitempty"`
+}
+
+func (x *StakeOperationMetadata) SetMeta(op *types.Operation, fee stypes.PriorityFee, rentExemptLamports uint64) error {
+	if err := decodeMetadata(op.Metadata, x); err != nil {
+		return err
+	}
 	if x.Lamports == 0 && op.Amount != nil {
 		x.Lamports = solanago.ValueToBaseAmount(op.Amount.Value)
 	}
+	if x.Stake == "" && op.Account != nil && op.Account.SubAccount != nil {
+		x.Stake = op.Account.SubAccount.Address
+	}
 	if x.Source == "" && op.Account != nil {
 		x.Source = op.Account.Address
 	}
@@ -48,16 +59,28 @@ func (x *StakeOperationMetadata) SetMeta(op *types.Operation, fee stypes.Priorit
 	if x.Withdrawer == "" && op.Account != nil {
 		x.Withdrawer = op.Account.Address
 	}
+	if x.Authority == "" && op.Account != nil {
+		x.Authority = op.Account.Address
+	}
+	if x.AuthorityBase == "" {
+		x.AuthorityBase = x.Authority
+	}
+	if x.RentExemptLamports == 0 {
+		x.RentExemptLamports = rentExemptLamports
+	}
 	if fee.MicroLamports != "" {
 		x.MicroLamportsUnitPrice = solanago.ValueToBaseAmount(fee.MicroLamports)
 	}
 	log.Printf("microLamportsUnitPrice=%v", x.MicroLamportsUnitPrice)
+	return nil
 }
-func (x *StakeOperationMetadata) ToInstructions(opType string) []solPTypes.Instruction {
+func (x *StakeOperationMetadata) ToInstructions(opType string) ([]solPTypes.Instruction, error) {
 	log.Printf("START stake ToInstructions")
 	log.Printf("opType=%v", opType)
 
 	var ins []solPTypes.Instruction
+	var in solPTypes.Instruction
+	var err error
 	ins = AddSetComputeUnitPriceParam(x.MicroLamportsUnitPrice, ins)
 	switch opType {
 	case stypes.Stake__CreateStakeAccount:
@@ -74,7 +97,6 @@ func (x *StakeOperationMetadata) ToInstructions(opType string) []solPTypes.Instr
 		ins = append(ins, stake.Deactivate(stake.DeactivateParam{Stake: p(x.Stake), Auth: p(x.Staker)}))
 		break
 	case stypes.Stake__WithdrawStake:
-		lockupCustodian := p(x.LockupCustodian)
 		ins = append(ins,
 			stake.Withdraw(
 				stake.WithdrawParam{
@@ -82,15 +104,15 @@ func (x *StakeOperationMetadata) ToInstructions(opType string) []solPTypes.Instr
 					Auth:      p(x.Withdrawer),
 					To:        p(x.WithdrawDestination),
 					Lamports:  x.Lamports,
-					Custodian: &lockupCustodian}))
+					Custodian: x.custodian()}))
 		break
 	case stypes.Stake__Merge:
 		ins = append(ins,
 			stake.Merge(
 				stake.MergeParam{
-					p(x.MergeDestination),
-					p(x.Stake),
-					p(x.Staker),
+					From: p(x.Stake),
+					Auth: p(x.Staker),
+					To:   p(x.MergeDestination),
 				}))
 		break
 	case stypes.Stake__Split:
@@ -103,7 +125,6 @@ func (x *StakeOperationMetadata) ToInstructions(opType string) []solPTypes.Instr
 					Lamports:   x.Lamports}))
 		break
 	case stypes.Stake__Authorize:
-		lockupCustodian := p(x.LockupCustodian)
 		ins = append(ins,
 			stake.Authorize(
 				stake.AuthorizeParam{
@@ -111,9 +132,76 @@ func (x *StakeOperationMetadata) ToInstructions(opType string) []solPTypes.Instr
 					Auth:      p(x.Authority),
 					NewAuth:   p(x.NewAuthority),
 					AuthType:  stake.StakeAuthorizationType(x.StakeAuthorizationType),
-					Custodian: &lockupCustodian,
+					Custodian: x.custodian(),
+				}))
+		break
+	case stypes.Stake__SplitWithSeed:
+		splitStake := x.SplitSeedAddress()
+		ins = append(ins,
+			system.CreateAccountWithSeed(
+				system.CreateAccountWithSeedParam{
+					From:     p(x.Staker),
+					New:      splitStake,
+					Base:     p(x.Staker),
+					Owner:    common.StakeProgramID,
+					Seed:     x.SplitSeed,
+					Lamports: x.RentExemptLamports,
+					Space:    stakeprog.AccountSize}),
+			stake.Split(
+				stake.SplitParam{
+					Stake:      p(x.Stake),
+					Auth:       p(x.Staker),
+					SplitStake: splitStake,
+					Lamports:   x.Lamports}))
+		break
+	case stypes.Stake__AuthorizeChecked:
+		in, err = authorizeChecked(x)
+		ins = append(ins, in)
+		break
+	case stypes.Stake__AuthorizeWithSeed:
+		ins = append(ins,
+			stake.AuthorizeWithSeed(
+				stake.AuthorizeWithSeedParam{
+					Stake:     p(x.Stake),
+					AuthBase:  p(x.AuthorityBase),
+					AuthSeed:  x.AuthoritySeed,
+					AuthOwner: x.authorityOwner(),
+					NewAuth:   p(x.NewAuthority),
+					AuthType:  stake.StakeAuthorizationType(x.StakeAuthorizationType),
+					Custodian: x.custodian(),
 				}))
 		break
+	case stypes.Stake__SetLockup:
+		ins = append(ins,
+			stake.SetLockup(
+				stake.SetLockupParam{
+					Stake: p(x.Stake),
+					Auth:  p(x.Authority),
+					Lockup: stake.LockupParam{
+						UnixTimestamp: x.lockupUnixTimestamp(),
+						Epoch:         x.lockupEpoch(),
+						Cusodian:      x.custodian(),
+					},
+				}))
+		break
+	case stypes.Stake__SetLockupChecked:
+		in, err = setLockupChecked(x)
+		ins = append(ins, in)
+		break
+	case stypes.Stake__InitializeChecked:
+		in, err = initializeChecked(x)
+		ins = append(ins, in)
+		break
+	case stypes.Stake__Redelegate:
+		in, err = redelegate(x)
+		ins = append(ins,
+			system.Allocate(system.AllocateParam{Account: p(x.RedelegateDestination), Space: stakeprog.AccountSize}),
+			system.Assign(system.AssignParam{From: p(x.RedelegateDestination), Owner: common.StakeProgramID}),
+			in)
+		break
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	log.Printf("There are %v instructions", len(ins))
@@ -129,7 +217,7 @@ func (x *StakeOperationMetadata) ToInstructions(opType string) []solPTypes.Instr
 	}
 
 	log.Printf("END stake ToInstructions")
-	return ins
+	return ins, nil
 }
 
 func addCreateStakeAccountIns(ins []solPTypes.Instruction, x *StakeOperationMetadata) []solPTypes.Instruction {
@@ -150,8 +238,8 @@ func addCreateStakeAccountIns(ins []solPTypes.Instruction, x *StakeOperationMeta
 					Withdrawer: p(x.Withdrawer),
 				},
 				Lockup: stake.Lockup{
-					UnixTimestamp: x.LockupUnixTimestamp,
-					Epoch:         x.LockupEpoch,
+					UnixTimestamp: x.initialLockupUnixTimestamp(),
+					Epoch:         x.initialLockupEpoch(),
 					Cusodian:      p(x.LockupCustodian),
 				}}),
 	)
@@ -161,3 +249,235 @@ func addCreateStakeAccountIns(ins []solPTypes.Instruction, x *StakeOperationMeta
 func addDelegateStakeIns(ins []solPTypes.Instruction, x *StakeOperationMetadata) []solPTypes.Instruction {
 	return append(ins, stake.DelegateStake(stake.DelegateStakeParam{Stake: p(x.Stake), Auth: p(x.Staker), Vote: p(x.VoteAccount)}))
 }
+
+// SplitSeedAddress derives the stake account Stake__SplitWithSeed splits
+// into from the staker and SplitSeed.
+func (x *StakeOperationMetadata) SplitSeedAddress() common.PublicKey {
+	return common.CreateWithSeed(p(x.Staker), x.SplitSeed, common.StakeProgramID)
+}
+
+// Validate checks the accounts of the operation, that the fields opType
+// needs are set, and the seed of a Stake__SplitWithSeed operation and
+// that a given split destination is the derived account.
+func (x *StakeOperationMetadata) Validate(opType string) error {
+	if err := checkAddresses(
+		"source", x.Source,
+		"stake", x.Stake,
+		"staker", x.Staker,
+		"withdrawer", x.Withdrawer,
+		"withdrawDestination", x.WithdrawDestination,
+		"lockupCustodian", x.LockupCustodian,
+		"voteAccount", x.VoteAccount,
+		"mergeDestination", x.MergeDestination,
+		"splitDestination", x.SplitDestination,
+		"authority", x.Authority,
+		"authorityBase", x.AuthorityBase,
+		"authorityOwner", x.AuthorityOwner,
+		"newAuthority", x.NewAuthority,
+		"redelegateDestination", x.RedelegateDestination,
+		"feePayer", x.FeePayer,
+	); err != nil {
+		return err
+	}
+	if err := requireFields("stake", x.Stake); err != nil {
+		return err
+	}
+	var required []string
+	switch opType {
+	case stypes.Stake__DelegateStake, stypes.Stake__CreateStakeAndDelegate:
+		required = []string{"voteAccount", x.VoteAccount}
+	case stypes.Stake__WithdrawStake:
+		required = []string{"withdrawDestination", x.WithdrawDestination}
+	case stypes.Stake__Merge:
+		required = []string{"mergeDestination", x.MergeDestination}
+	case stypes.Stake__Split:
+		required = []string{"splitDestination", x.SplitDestination}
+	case stypes.Stake__Authorize, stypes.Stake__AuthorizeChecked:
+		required = []string{"newAuthority", x.NewAuthority}
+	case stypes.Stake__AuthorizeWithSeed:
+		required = []string{"newAuthority", x.NewAuthority, "authoritySeed", x.AuthoritySeed}
+	case stypes.Stake__Redelegate:
+		required = []string{"redelegateDestination", x.RedelegateDestination, "voteAccount", x.VoteAccount}
+	case stypes.Stake__CreateStakeAccount, stypes.Stake__DeactivateStake, stypes.Stake__SplitWithSeed, stypes.Stake__SetLockup, stypes.Stake__SetLockupChecked, stypes.Stake__InitializeChecked:
+	default:
+		return unsupported(opType)
+	}
+	if err := requireFields(required...); err != nil {
+		return err
+	}
+	if x.StakeAuthorizationType > 1 {
+		return FieldErrorf("metadata.stakeAuthorizationType", "must be 0 (staker) or 1 (withdrawer)")
+	}
+	if opType != stypes.Stake__SplitWithSeed {
+		return nil
+	}
+	if x.SplitSeed == "" {
+		return FieldErrorf("metadata.splitSeed", "is required")
+	}
+	if len(x.SplitSeed) > common.MaxSeedLength {
+		return FieldErrorf("metadata.splitSeed", "is longer than %d bytes", common.MaxSeedLength)
+	}
+	if splitStake := x.SplitSeedAddress().ToBase58(); x.SplitDestination != "" && x.SplitDestination != splitStake {
+		return FieldErrorf("metadata.splitDestination", "%s does not match the seeded address %s", x.SplitDestination, splitStake)
+	}
+	return nil
+}
+
+// custodian is the lockup custodian signing the instruction, nil when
+// no lockup is in force.
+func (x *StakeOperationMetadata) custodian() *common.PublicKey {
+	if x.LockupCustodian == "" {
+		return nil
+	}
+	custodian := p(x.LockupCustodian)
+	return &custodian
+}
+
+// authorityOwner is the program owning a seed-derived authority, the
+// system program unless specified.
+func (x *StakeOperationMetadata) authorityOwner() common.PublicKey {
+	if x.AuthorityOwner == "" {
+		return common.SystemProgramID
+	}
+	return p(x.AuthorityOwner)
+}
+
+// lockupUnixTimestamp and lockupEpoch leave a lockup field unchanged
+// when it is not given; a given 0 clears it.
+func (x *StakeOperationMetadata) lockupUnixTimestamp() *int64 {
+	return x.LockupUnixTimestamp
+}
+
+func (x *StakeOperationMetadata) lockupEpoch() *uint64 {
+	return x.LockupEpoch
+}
+
+// initialLockupUnixTimestamp and initialLockupEpoch are the lockup of a
+// new stake account, none when not given.
+func (x *StakeOperationMetadata) initialLockupUnixTimestamp() int64 {
+	if x.LockupUnixTimestamp == nil {
+		return 0
+	}
+	return *x.LockupUnixTimestamp
+}
+
+func (x *StakeOperationMetadata) initialLockupEpoch() uint64 {
+	if x.LockupEpoch == nil {
+		return 0
+	}
+	return *x.LockupEpoch
+}
+
+// The checked stake instructions and Redelegate are not built by the
+// sdk, so their data is serialized here the same way it does.
+const (
+	stakeInstructionInitializeChecked stake.Instruction = 9
+	stakeInstructionAuthorizeChecked  stake.Instruction = 10
+	stakeInstructionSetLockupChecked  stake.Instruction = 12
+	stakeInstructionRedelegate        stake.Instruction = 15
+)
+
+func serializeStakeData(data interface{}) ([]byte, error) {
+	b, err := bincode.SerializeData(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot serialize stake instruction: %w", err)
+	}
+	return b, nil
+}
+
+func initializeChecked(x *StakeOperationMetadata) (solPTypes.Instruction, error) {
+	data, err := serializeStakeData(struct {
+		Instruction stake.Instruction
+	}{
+		Instruction: stakeInstructionInitializeChecked,
+	})
+	if err != nil {
+		return solPTypes.Instruction{}, err
+	}
+	return solPTypes.Instruction{
+		ProgramID: common.StakeProgramID,
+		Accounts: []solPTypes.AccountMeta{
+			{PubKey: p(x.Stake), IsSigner: false, IsWritable: true},
+			{PubKey: common.SysVarRentPubkey, IsSigner: false, IsWritable: false},
+			{PubKey: p(x.Staker), IsSigner: false, IsWritable: false},
+			{PubKey: p(x.Withdrawer), IsSigner: true, IsWritable: false},
+		},
+		Data: data,
+	}, nil
+}
+
+func authorizeChecked(x *StakeOperationMetadata) (solPTypes.Instruction, error) {
+	accounts := []solPTypes.AccountMeta{
+		{PubKey: p(x.Stake), IsSigner: false, IsWritable: true},
+		{PubKey: common.SysVarClockPubkey, IsSigner: false, IsWritable: false},
+		{PubKey: p(x.Authority), IsSigner: true, IsWritable: false},
+		{PubKey: p(x.NewAuthority), IsSigner: true, IsWritable: false},
+	}
+	if custodian := x.custodian(); custodian != nil {
+		accounts = append(accounts, solPTypes.AccountMeta{PubKey: *custodian, IsSigner: true, IsWritable: false})
+	}
+	data, err := serializeStakeData(struct {
+		Instruction            stake.Instruction
+		StakeAuthorizationType stake.StakeAuthorizationType
+	}{
+		Instruction:            stakeInstructionAuthorizeChecked,
+		StakeAuthorizationType: stake.StakeAuthorizationType(x.StakeAuthorizationType),
+	})
+	if err != nil {
+		return solPTypes.Instruction{}, err
+	}
+	return solPTypes.Instruction{
+		ProgramID: common.StakeProgramID,
+		Accounts:  accounts,
+		Data:      data,
+	}, nil
+}
+
+func setLockupChecked(x *StakeOperationMetadata) (solPTypes.Instruction, error) {
+	accounts := []solPTypes.AccountMeta{
+		{PubKey: p(x.Stake), IsSigner: false, IsWritable: true},
+		{PubKey: p(x.Authority), IsSigner: true, IsWritable: false},
+	}
+	if custodian := x.custodian(); custodian != nil {
+		accounts = append(accounts, solPTypes.AccountMeta{PubKey: *custodian, IsSigner: true, IsWritable: false})
+	}
+	data, err := serializeStakeData(struct {
+		Instruction   stake.Instruction
+		UnixTimestamp *int64
+		Epoch         *uint64
+	}{
+		Instruction:   stakeInstructionSetLockupChecked,
+		UnixTimestamp: x.lockupUnixTimestamp(),
+		Epoch:         x.lockupEpoch(),
+	})
+	if err != nil {
+		return solPTypes.Instruction{}, err
+	}
+	return solPTypes.Instruction{
+		ProgramID: common.StakeProgramID,
+		Accounts:  accounts,
+		Data:      data,
+	}, nil
+}
+
+func redelegate(x *StakeOperationMetadata) (solPTypes.Instruction, error) {
+	data, err := serializeStakeData(struct {
+		Instruction stake.Instruction
+	}{
+		Instruction: stakeInstructionRedelegate,
+	})
+	if err != nil {
+		return solPTypes.Instruction{}, err
+	}
+	return solPTypes.Instruction{
+		ProgramID: common.StakeProgramID,
+		Accounts: []solPTypes.AccountMeta{
+			{PubKey: p(x.Stake), IsSigner: false, IsWritable: true},
+			{PubKey: p(x.RedelegateDestination), IsSigner: false, IsWritable: true},
+			{PubKey: p(x.VoteAccount), IsSigner: false, IsWritable: false},
+			{PubKey: common.StakeConfigPubkey, IsSigner: false, IsWritable: false},
+			{PubKey: p(x.Staker), IsSigner: true, IsWritable: false},
+		},
+		Data: data,
+	}, nil
+}
@@ -1,7 +1,6 @@
 package operations
 
 import (
-	"encoding/json"
 	"github.com/blocto/solana-go-sdk/common"
 	"github.com/blocto/solana-go-sdk/program/sysprog"
 	"github.com/blocto/solana-go-sdk/program/system"
@@ -19,26 +18,142 @@ type SystemOperationMetadata struct {
 	Lamports               uint64 `json:"lamports,omitempty"`
 	NewAuthority           string `json:"new_authority,omitempty"`
 	Authority              string `json:"authority,omitempty"`
+	NonceAccount           string `json:"nonce_account,omitempty"`
+	Base                   string `json:"base,omitempty"`
+	Seed                   string `json:"seed,omitempty"`
+	Owner                  string `json:"owner,omitempty"`
 	MicroLamportsUnitPrice uint64 `json:"micro_lamports_unit_price,omitempty"`
 }
 
-func (x *SystemOperationMetadata) SetMeta(op *types.Operation, fee stypes.PriorityFee) {
-	jsonString, _ := json.Marshal(op.Metadata)
-	json.Unmarshal(jsonString, &x)
-	if x.Lamports == 0 {
+func (x *SystemOperationMetadata) SetMeta(op *types.Operation, fee stypes.PriorityFee, nonce stypes.NonceMetadata) error {
+	if err := decodeMetadata(op.Metadata, x); err != nil {
+		return err
+	}
+	if x.Lamports == 0 && op.Amount != nil {
 		x.Lamports = solanago.ValueToBaseAmount(op.Amount.Value)
 	}
 	if x.Source == "" {
 		x.Source = op.Account.Address
 	}
+	nonceAccount, hasNonceAccount := nonce.Accounts[x.NonceAccountAddress(op.Type)]
+	if x.Authority == "" && hasNonceAccount {
+		x.Authority = nonceAccount.Authority
+	}
 	if x.Authority == "" {
 		x.Authority = x.Source
 	}
+	if x.Base == "" {
+		x.Base = x.Source
+	}
+	switch op.Type {
+	case stypes.System__CreateNonceAccount:
+		if x.Lamports == 0 {
+			x.Lamports = nonce.RentExemptLamports
+		}
+	case stypes.System__CloseNonceAccount:
+		if hasNonceAccount {
+			x.Lamports = nonceAccount.Lamports
+		}
+	}
 	if fee.MicroLamports != "" {
 		x.MicroLamportsUnitPrice = solanago.ValueToBaseAmount(fee.MicroLamports)
 	}
 	log.Printf("microLamportsUnitPrice=%v", x.MicroLamportsUnitPrice)
+	return nil
+}
+
+// SeededAddress derives the address of the account created from Base,
+// Seed and Owner by the *WithSeed operations.
+func (x *SystemOperationMetadata) SeededAddress() common.PublicKey {
+	return common.CreateWithSeed(p(x.Base), x.Seed, x.seedOwner())
+}
+
+// seedOwner is the program owning a seeded account, the system
+// program unless specified.
+func (x *SystemOperationMetadata) seedOwner() common.PublicKey {
+	return x.ownerOr(common.SystemProgramID)
+}
+
+// accountOwner is the program assigned by CreateAccount and Assign. It
+// stays the token program unless specified, which is what these
+// operations were first used for.
+func (x *SystemOperationMetadata) accountOwner() common.PublicKey {
+	return x.ownerOr(common.TokenProgramID)
+}
+
+func (x *SystemOperationMetadata) ownerOr(def common.PublicKey) common.PublicKey {
+	if x.Owner == "" {
+		return def
+	}
+	return p(x.Owner)
+}
+
+// Validate checks the accounts of the operation, that the fields opType
+// needs are set, and that the seed and the derived address of a
+// *WithSeed operation agree with the accounts given in the operation.
+func (x *SystemOperationMetadata) Validate(opType string) error {
+	if err := checkAddresses("source", x.Source, "destination", x.Destination, "new_authority", x.NewAuthority, "authority", x.Authority, "nonce_account", x.NonceAccount, "base", x.Base, "owner", x.Owner); err != nil {
+		return err
+	}
+	switch opType {
+	case stypes.System__Transfer, stypes.System__CreateAccount, stypes.System__CreateNonceAccount, stypes.System__WithdrawFromNonce, stypes.System__CloseNonceAccount, stypes.System__TransferWithSeed:
+		if err := requireFields("destination", x.Destination); err != nil {
+			return err
+		}
+	case stypes.System__AdvanceNonce, stypes.System__AuthorizeNonce:
+		if err := requireFields("nonce_account", x.NonceAccountAddress(opType)); err != nil {
+			return err
+		}
+		if opType == stypes.System__AuthorizeNonce {
+			if err := requireFields("new_authority", x.NewAuthority); err != nil {
+				return err
+			}
+		}
+	case stypes.System__Assign, stypes.System__Allocate, stypes.System__CreateAccountWithSeed, stypes.System__AllocateWithSeed, stypes.System__AssignWithSeed:
+	default:
+		return unsupported(opType)
+	}
+	switch opType {
+	case stypes.System__CreateAccountWithSeed, stypes.System__AllocateWithSeed, stypes.System__AssignWithSeed, stypes.System__TransferWithSeed:
+		if x.Seed == "" {
+			return FieldErrorf("metadata.seed", "is required")
+		}
+		if len(x.Seed) > common.MaxSeedLength {
+			return FieldErrorf("metadata.seed", "is longer than %d bytes", common.MaxSeedLength)
+		}
+	}
+	seeded := x.SeededAddress().ToBase58()
+	switch opType {
+	case stypes.System__CreateAccountWithSeed:
+		if x.Destination != "" && x.Destination != seeded {
+			return FieldErrorf("metadata.destination", "%s does not match the seeded address %s", x.Destination, seeded)
+		}
+	case stypes.System__TransferWithSeed:
+		if x.Source != x.Base && x.Source != seeded {
+			return FieldErrorf("account.address", "%s does not match the seeded address %s", x.Source, seeded)
+		}
+	}
+	return nil
 }
+
+// NonceAccountAddress returns the existing nonce account an operation of
+// opType acts on, or "" if the operation does not use one.
+func (x *SystemOperationMetadata) NonceAccountAddress(opType string) string {
+	switch opType {
+	case stypes.System__WithdrawFromNonce, stypes.System__CloseNonceAccount:
+		if x.NonceAccount != "" {
+			return x.NonceAccount
+		}
+		return x.Source
+	case stypes.System__AdvanceNonce, stypes.System__AuthorizeNonce:
+		if x.NonceAccount != "" {
+			return x.NonceAccount
+		}
+		return x.Destination
+	}
+	return ""
+}
+
 func (x *SystemOperationMetadata) ToInstructions(opType string) []solPTypes.Instruction {
 	log.Printf("START system ToInstructions")
 	log.Printf("opType=%v", opType)
@@ -48,10 +163,10 @@ func (x *SystemOperationMetadata) ToInstructions(opType string) []solPTypes.Inst
 	switch opType {
 	case stypes.System__CreateAccount:
 		log.Printf("System__CreateAccount adding CreateAccount")
-		ins = append(ins, system.CreateAccount(system.CreateAccountParam{From: p(x.Source), New: p(x.Destination), Owner: common.TokenProgramID, Lamports: x.Lamports, Space: x.Space}))
+		ins = append(ins, system.CreateAccount(system.CreateAccountParam{From: p(x.Source), New: p(x.Destination), Owner: x.accountOwner(), Lamports: x.Lamports, Space: x.Space}))
 		break
 	case stypes.System__Assign:
-		ins = append(ins, system.Assign(system.AssignParam{From: p(x.Source), Owner: common.TokenProgramID}))
+		ins = append(ins, system.Assign(system.AssignParam{From: p(x.Source), Owner: x.accountOwner()}))
 		break
 	case stypes.System__Transfer:
 
@@ -75,16 +190,28 @@ func (x *SystemOperationMetadata) ToInstructions(opType string) []solPTypes.Inst
 		break
 	case stypes.System__AdvanceNonce:
 		log.Printf("System__AdvanceNonce adding AdvanceNonceAccount")
-		ins = append(ins, system.AdvanceNonceAccount(system.AdvanceNonceAccountParam{Nonce: p(x.Destination), Auth: p(x.Authority)}))
+		ins = append(ins, system.AdvanceNonceAccount(system.AdvanceNonceAccountParam{Nonce: p(x.NonceAccountAddress(opType)), Auth: p(x.Authority)}))
 		break
-	case stypes.System__WithdrawFromNonce:
-		ins = append(ins, system.WithdrawNonceAccount(system.WithdrawNonceAccountParam{p(x.Source), p(x.Authority), p(x.Destination), x.Lamports}))
+	case stypes.System__WithdrawFromNonce, stypes.System__CloseNonceAccount:
+		ins = append(ins, system.WithdrawNonceAccount(system.WithdrawNonceAccountParam{Nonce: p(x.NonceAccountAddress(opType)), Auth: p(x.Authority), To: p(x.Destination), Amount: x.Lamports}))
 		break
 	case stypes.System__AuthorizeNonce:
-		ins = append(ins, system.AuthorizeNonceAccount(system.AuthorizeNonceAccountParam{p(x.Destination), p(x.Authority), p(x.NewAuthority)}))
+		ins = append(ins, system.AuthorizeNonceAccount(system.AuthorizeNonceAccountParam{Nonce: p(x.NonceAccountAddress(opType)), Auth: p(x.Authority), NewAuth: p(x.NewAuthority)}))
 		break
 	case stypes.System__Allocate:
-		ins = append(ins, system.Allocate(system.AllocateParam{p(x.Source), x.Space}))
+		ins = append(ins, system.Allocate(system.AllocateParam{Account: p(x.Source), Space: x.Space}))
+		break
+	case stypes.System__CreateAccountWithSeed:
+		ins = append(ins, system.CreateAccountWithSeed(system.CreateAccountWithSeedParam{From: p(x.Source), New: x.SeededAddress(), Base: p(x.Base), Owner: x.seedOwner(), Seed: x.Seed, Lamports: x.Lamports, Space: x.Space}))
+		break
+	case stypes.System__AllocateWithSeed:
+		ins = append(ins, system.AllocateWithSeed(system.AllocateWithSeedParam{Account: x.SeededAddress(), Base: p(x.Base), Owner: x.seedOwner(), Seed: x.Seed, Space: x.Space}))
+		break
+	case stypes.System__AssignWithSeed:
+		ins = append(ins, system.AssignWithSeed(system.AssignWithSeedParam{Account: x.SeededAddress(), Owner: x.seedOwner(), Base: p(x.Base), Seed: x.Seed}))
+		break
+	case stypes.System__TransferWithSeed:
+		ins = append(ins, system.TransferWithSeed(system.TransferWithSeedParam{From: x.SeededAddress(), To: p(x.Destination), Base: p(x.Base), Owner: x.seedOwner(), Seed: x.Seed, Amount: x.Lamports}))
 		break
 	}
 	log.Printf("There are %v instructions", len(ins))
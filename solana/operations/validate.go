@@ -0,0 +1,76 @@
+package operations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/mr-tron/base58"
+)
+
+// FieldError is an operation field that is missing or invalid. Field is
+// the path of the field in the operation, e.g. metadata.destination.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrorf returns a FieldError for field with a formatted error.
+func FieldErrorf(field string, format string, a ...interface{}) error {
+	return &FieldError{Field: field, Err: fmt.Errorf(format, a...)}
+}
+
+// IsAddress reports whether s is a base58 encoded public key.
+func IsAddress(s string) bool {
+	b, err := base58.Decode(s)
+	return err == nil && len(b) == common.PublicKeyLength
+}
+
+// checkAddresses takes metadata field names and values in pairs and
+// returns an error for the first value that is set but not an address.
+func checkAddresses(fields ...string) error {
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i+1] != "" && !IsAddress(fields[i+1]) {
+			return FieldErrorf("metadata."+fields[i], "%s is not a valid public key", fields[i+1])
+		}
+	}
+	return nil
+}
+
+// requireFields takes metadata field names and values in pairs and
+// returns an error for the first value that is not set.
+func requireFields(fields ...string) error {
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i+1] == "" {
+			return FieldErrorf("metadata."+fields[i], "is required")
+		}
+	}
+	return nil
+}
+
+// decodeMetadata unmarshals metadata into v, naming the field that has
+// a value of the wrong type.
+func decodeMetadata(metadata map[string]interface{}, v interface{}) error {
+	jsonString, _ := json.Marshal(metadata)
+	err := json.Unmarshal(jsonString, v)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return FieldErrorf("metadata."+typeErr.Field, "must be %s, not %s", typeErr.Type, typeErr.Value)
+	}
+	if err != nil {
+		return FieldErrorf("metadata", "%v", err)
+	}
+	return nil
+}
+
+func unsupported(opType string) error {
+	return FieldErrorf("type", "%s is not supported for construction", opType)
+}
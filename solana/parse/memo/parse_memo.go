@@ -0,0 +1,32 @@
+package memo
+
+import (
+	"fmt"
+	"github.com/blocto/solana-go-sdk/types"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+	"unicode/utf8"
+)
+
+func ParseMemo(ins types.Instruction) (stypes.ParsedInstruction, error) {
+	var parsedInstruction stypes.ParsedInstruction
+	if !utf8.Valid(ins.Data) {
+		return parsedInstruction, fmt.Errorf("memo is not valid utf-8")
+	}
+	parsedInfo := map[string]interface{}{
+		"memo": string(ins.Data),
+	}
+	var signers []string
+	for _, v := range ins.Accounts {
+		if v.IsSigner {
+			signers = append(signers, v.PubKey.ToBase58())
+		}
+	}
+	if len(signers) > 0 {
+		parsedInfo["signers"] = signers
+	}
+	parsedInstruction.Parsed = &stypes.InstructionInfo{
+		Info:            parsedInfo,
+		InstructionType: "memo",
+	}
+	return parsedInstruction, nil
+}
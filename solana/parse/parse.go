@@ -5,6 +5,8 @@ import (
 	types "github.com/blocto/solana-go-sdk/types"
 	"github.com/imerkle/rosetta-solana-go/solana/parse/associatedtokenaccount"
 	"github.com/imerkle/rosetta-solana-go/solana/parse/computebudget"
+	"github.com/imerkle/rosetta-solana-go/solana/parse/memo"
+	"github.com/imerkle/rosetta-solana-go/solana/parse/stake"
 	"github.com/imerkle/rosetta-solana-go/solana/parse/system"
 	"github.com/imerkle/rosetta-solana-go/solana/parse/token"
 	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
@@ -22,6 +24,8 @@ var (
 	TokenProgramID                     = common.PublicKeyFromString("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
 	SPLAssociatedTokenAccountProgramID = common.PublicKeyFromString("ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL")
 	ComputeBudgetProgramID             = common.PublicKeyFromString("ComputeBudget111111111111111111111111111111")
+	MemoProgramID                      = common.PublicKeyFromString("MemoSq4gqABAXKb96qnH8TysNcWxMyWCqXgDLGmfcHr")
+	MemoV1ProgramID                    = common.PublicKeyFromString("Memo1UhkJRfHyvLMcVucJwxXeuD728EqVDDwQDxFMNo")
 )
 
 func ToParsedTransaction(tx types.Transaction) (stypes.ParsedTransaction, error) {
@@ -80,12 +84,25 @@ func ParseInstruction(ins types.Instruction) (stypes.ParsedInstruction, error) {
 		}
 		break
 
+	case common.StakeProgramID:
+		parsedInstruction, err = stake.ParseStake(ins)
+		if err != nil {
+			log.Printf("error parsing StakeProgramID instruction: %v", err)
+		}
+		break
+
 	case common.ComputeBudgetProgramID:
 		parsedInstruction, err = computebudget.ParseComputeBudget(ins)
 		if err != nil {
 			log.Printf("error parsing ComputeBudgetProgramID instruction: %v", err)
 		}
 		break
+	case MemoProgramID, MemoV1ProgramID:
+		parsedInstruction, err = memo.ParseMemo(ins)
+		if err != nil {
+			log.Printf("error parsing MemoProgramID instruction: %v", err)
+		}
+		break
 	default:
 		log.Printf("error parsing instruction. ins.ProgramI=%v is unknown", ins.ProgramID)
 		//return parsedInstruction, fmt.Errorf("Cannot parse instruction")
@@ -135,6 +152,9 @@ func GetProgramName(programId common.PublicKey) string {
 	case ComputeBudgetProgramID:
 		name = "compute-budget"
 		break
+	case MemoProgramID, MemoV1ProgramID:
+		name = "spl-memo"
+		break
 	}
 	return name
 }
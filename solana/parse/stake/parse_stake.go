@@ -0,0 +1,315 @@
+package stake
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/ghostiam/binstruct"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
+	"log"
+)
+
+type Instruction uint32
+
+const (
+	InstructionInitialize Instruction = iota
+	InstructionAuthorize
+	InstructionDelegateStake
+	InstructionSplit
+	InstructionWithdraw
+	InstructionDeactivate
+	InstructionSetLockup
+	InstructionMerge
+	InstructionAuthorizeWithSeed
+	InstructionInitializeChecked
+	InstructionAuthorizeChecked
+	InstructionAuthorizeCheckedWithSeed
+	InstructionSetLockupChecked
+	InstructionGetMinimumDelegation
+	InstructionDeactivateDelinquent
+	InstructionRedelegate
+)
+
+// AuthorityTypes names the stake authorization types the way the
+// jsonParsed encoding of the node does.
+var AuthorityTypes = []string{"Staker", "Withdrawer"}
+
+func ParseStake(ins types.Instruction) (stypes.ParsedInstruction, error) {
+	var parsedInstruction stypes.ParsedInstruction
+	var err error
+	var s struct {
+		Instruction Instruction
+	}
+	err = binstruct.UnmarshalLE(ins.Data, &s)
+	if err != nil {
+		return parsedInstruction, err
+	}
+	account := func(i int) string {
+		if i >= len(ins.Accounts) {
+			err = fmt.Errorf("stake instruction %d has no account %d", s.Instruction, i)
+			return ""
+		}
+		return ins.Accounts[i].PubKey.ToBase58()
+	}
+	// the custodian is an optional trailing signer
+	withCustodian := func(info map[string]interface{}, i int) map[string]interface{} {
+		if i < len(ins.Accounts) {
+			info["custodian"] = ins.Accounts[i].PubKey.ToBase58()
+		}
+		return info
+	}
+	var instructionType string
+	var parsedInfo map[string]interface{}
+	switch s.Instruction {
+	case InstructionInitialize:
+		var a InitializeInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "initialize"
+		parsedInfo = map[string]interface{}{
+			"stakeAccount": account(0),
+			"rentSysvar":   account(1),
+			"authorized": map[string]interface{}{
+				"staker":     a.Staker.ToBase58(),
+				"withdrawer": a.Withdrawer.ToBase58(),
+			},
+			"lockup": map[string]interface{}{
+				"unixTimestamp": a.UnixTimestamp,
+				"epoch":         a.Epoch,
+				"custodian":     a.Custodian.ToBase58(),
+			},
+		}
+		break
+	case InstructionAuthorize:
+		var a AuthorizeInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "authorize"
+		parsedInfo = withCustodian(map[string]interface{}{
+			"stakeAccount":  account(0),
+			"clockSysvar":   account(1),
+			"authority":     account(2),
+			"newAuthority":  a.NewAuthority.ToBase58(),
+			"authorityType": authorityType(a.AuthorityType),
+		}, 3)
+		break
+	case InstructionDelegateStake:
+		instructionType = "delegate"
+		parsedInfo = map[string]interface{}{
+			"stakeAccount":       account(0),
+			"voteAccount":        account(1),
+			"clockSysvar":        account(2),
+			"stakeHistorySysvar": account(3),
+			"stakeConfigAccount": account(4),
+			"stakeAuthority":     account(5),
+		}
+		break
+	case InstructionSplit:
+		var a LamportsInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "split"
+		parsedInfo = map[string]interface{}{
+			"stakeAccount":    account(0),
+			"newSplitAccount": account(1),
+			"stakeAuthority":  account(2),
+			"lamports":        a.Lamports,
+		}
+		break
+	case InstructionWithdraw:
+		var a LamportsInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "withdraw"
+		parsedInfo = withCustodian(map[string]interface{}{
+			"stakeAccount":       account(0),
+			"destination":        account(1),
+			"clockSysvar":        account(2),
+			"stakeHistorySysvar": account(3),
+			"withdrawAuthority":  account(4),
+			"lamports":           a.Lamports,
+		}, 5)
+		break
+	case InstructionDeactivate:
+		instructionType = "deactivate"
+		parsedInfo = map[string]interface{}{
+			"stakeAccount":   account(0),
+			"clockSysvar":    account(1),
+			"stakeAuthority": account(2),
+		}
+		break
+	case InstructionSetLockup:
+		var lockup map[string]interface{}
+		lockup, err = parseLockupArgs(ins.Data[4:], true)
+		instructionType = "setLockup"
+		parsedInfo = map[string]interface{}{
+			"stakeAccount": account(0),
+			"custodian":    account(1),
+			"lockup":       lockup,
+		}
+		break
+	case InstructionMerge:
+		instructionType = "merge"
+		parsedInfo = map[string]interface{}{
+			"destination":        account(0),
+			"source":             account(1),
+			"clockSysvar":        account(2),
+			"stakeHistorySysvar": account(3),
+			"stakeAuthority":     account(4),
+		}
+		break
+	case InstructionAuthorizeWithSeed:
+		var a AuthorizeWithSeedInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "authorizeWithSeed"
+		parsedInfo = withCustodian(map[string]interface{}{
+			"stakeAccount":   account(0),
+			"authorityBase":  account(1),
+			"clockSysvar":    account(2),
+			"newAuthorized":  a.NewAuthority.ToBase58(),
+			"authorityType":  authorityType(a.AuthorityType),
+			"authoritySeed":  a.Seed,
+			"authorityOwner": a.Owner.ToBase58(),
+		}, 3)
+		break
+	case InstructionInitializeChecked:
+		instructionType = "initializeChecked"
+		parsedInfo = map[string]interface{}{
+			"stakeAccount": account(0),
+			"rentSysvar":   account(1),
+			"staker":       account(2),
+			"withdrawer":   account(3),
+		}
+		break
+	case InstructionAuthorizeChecked:
+		var a AuthorizeCheckedInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "authorizeChecked"
+		parsedInfo = withCustodian(map[string]interface{}{
+			"stakeAccount":  account(0),
+			"clockSysvar":   account(1),
+			"authority":     account(2),
+			"newAuthority":  account(3),
+			"authorityType": authorityType(a.AuthorityType),
+		}, 4)
+		break
+	case InstructionSetLockupChecked:
+		var lockup map[string]interface{}
+		lockup, err = parseLockupArgs(ins.Data[4:], false)
+		if len(ins.Accounts) > 2 {
+			lockup["custodian"] = account(2)
+		}
+		instructionType = "setLockupChecked"
+		parsedInfo = map[string]interface{}{
+			"stakeAccount": account(0),
+			"custodian":    account(1),
+			"lockup":       lockup,
+		}
+		break
+	case InstructionRedelegate:
+		instructionType = "redelegate"
+		parsedInfo = map[string]interface{}{
+			"stakeAccount":       account(0),
+			"newStakeAccount":    account(1),
+			"voteAccount":        account(2),
+			"stakeConfigAccount": account(3),
+			"stakeAuthority":     account(4),
+		}
+		break
+	}
+
+	if err != nil {
+		log.Printf("error parsing instruction: %v", err)
+		return parsedInstruction, err
+	}
+
+	parsedInstruction.Parsed = &stypes.InstructionInfo{
+		Info:            parsedInfo,
+		InstructionType: instructionType,
+	}
+	return parsedInstruction, nil
+}
+
+func authorityType(t uint32) string {
+	if int(t) < len(AuthorityTypes) {
+		return AuthorityTypes[t]
+	}
+	return fmt.Sprint(t)
+}
+
+// parseLockupArgs decodes the optional unix timestamp, epoch and, if
+// withCustodian, custodian of SetLockup and SetLockupChecked. Fields that
+// are not set are left out.
+func parseLockupArgs(data []byte, withCustodian bool) (map[string]interface{}, error) {
+	lockup := map[string]interface{}{}
+	option := func(size int) ([]byte, error) {
+		if len(data) < 1 {
+			return nil, fmt.Errorf("lockup args too short")
+		}
+		set := data[0] == 1
+		data = data[1:]
+		if !set {
+			return nil, nil
+		}
+		if len(data) < size {
+			return nil, fmt.Errorf("lockup args too short")
+		}
+		v := data[:size]
+		data = data[size:]
+		return v, nil
+	}
+	v, err := option(8)
+	if err != nil {
+		return nil, err
+	}
+	if v != nil {
+		lockup["unixTimestamp"] = int64(binary.LittleEndian.Uint64(v))
+	}
+	if v, err = option(8); err != nil {
+		return nil, err
+	}
+	if v != nil {
+		lockup["epoch"] = binary.LittleEndian.Uint64(v)
+	}
+	if !withCustodian {
+		return lockup, nil
+	}
+	if v, err = option(common.PublicKeyLength); err != nil {
+		return nil, err
+	}
+	if v != nil {
+		lockup["custodian"] = common.PublicKeyFromBytes(v).ToBase58()
+	}
+	return lockup, nil
+}
+
+type InitializeInstruction struct {
+	Instruction   Instruction
+	Staker        common.PublicKey
+	Withdrawer    common.PublicKey
+	UnixTimestamp int64
+	Epoch         uint64
+	Custodian     common.PublicKey
+}
+
+type AuthorizeInstruction struct {
+	Instruction   Instruction
+	NewAuthority  common.PublicKey
+	AuthorityType uint32
+}
+
+type LamportsInstruction struct {
+	Instruction Instruction
+	Lamports    uint64
+}
+
+type AuthorizeWithSeedInstruction struct {
+	Instruction   Instruction
+	NewAuthority  common.PublicKey
+	AuthorityType uint32
+	SeedLen       uint64
+	Seed          string `bin:"len:SeedLen"`
+	Owner         common.PublicKey
+}
+
+type AuthorizeCheckedInstruction struct {
+	Instruction   Instruction
+	AuthorityType uint32
+}
@@ -87,11 +87,11 @@ func ParseSystem(ins types.Instruction) (stypes.ParsedInstruction, error) {
 		parsedInfo = map[string]interface{}{
 			"source":     ins.Accounts[0].PubKey.ToBase58(),
 			"newAccount": ins.Accounts[1].PubKey.ToBase58(),
-			"base":       a.Base,
+			"base":       a.Base.ToBase58(),
 			"seed":       a.Seed,
 			"space":      a.Space,
 			"lamports":   a.Lamports,
-			"owner":      a.ProgramID,
+			"owner":      a.ProgramID.ToBase58(),
 		}
 		break
 	case InstructionAdvanceNonceAccount:
@@ -147,7 +147,6 @@ func ParseSystem(ins types.Instruction) (stypes.ParsedInstruction, error) {
 		parsedInfo = map[string]interface{}{
 			"nonceAccount":   ins.Accounts[0].PubKey.ToBase58(),
 			"nonceAuthority": ins.Accounts[1].PubKey.ToBase58(),
-			"rentSysvar":     ins.Accounts[2].PubKey.ToBase58(),
 			"newAuthorized":  a.Auth.ToBase58(),
 		}
 		break
@@ -172,7 +171,7 @@ func ParseSystem(ins types.Instruction) (stypes.ParsedInstruction, error) {
 		instructionType = "allocateWithSeed"
 		parsedInfo = map[string]interface{}{
 			"account": ins.Accounts[0].PubKey.ToBase58(),
-			"base":    a.Base,
+			"base":    a.Base.ToBase58(),
 			"seed":    a.Seed,
 			"space":   a.Space,
 			"owner":   a.ProgramID.ToBase58(),
@@ -187,7 +186,7 @@ func ParseSystem(ins types.Instruction) (stypes.ParsedInstruction, error) {
 		instructionType = "assignWithSeed"
 		parsedInfo = map[string]interface{}{
 			"account": ins.Accounts[0].PubKey.ToBase58(),
-			"base":    a.Base,
+			"base":    a.Base.ToBase58(),
 			"seed":    a.Seed,
 			"owner":   a.AssignToProgramID.ToBase58(),
 		}
@@ -230,7 +229,7 @@ type CreateAccountWithSeedInstruction struct {
 	Instruction Instruction
 	Base        common.PublicKey
 	SeedLen     uint64
-	Seed        string
+	Seed        string `bin:"len:SeedLen"`
 	Lamports    uint64
 	Space       uint64
 	ProgramID   common.PublicKey
@@ -260,7 +259,7 @@ type AllocateWithSeedInstruction struct {
 	Instruction Instruction
 	Base        common.PublicKey
 	SeedLen     uint64
-	Seed        string
+	Seed        string `bin:"len:SeedLen"`
 	Space       uint64
 	ProgramID   common.PublicKey
 }
@@ -268,14 +267,14 @@ type AssignWithSeedInstruction struct {
 	Instruction       Instruction
 	Base              common.PublicKey
 	SeedLen           uint64
-	Seed              string
+	Seed              string `bin:"len:SeedLen"`
 	AssignToProgramID common.PublicKey
 }
 type TransferWithSeedInstruction struct {
 	Instruction Instruction
 	Lamports    uint64
 	SeedLen     uint64
-	Seed        string
+	Seed        string `bin:"len:SeedLen"`
 	ProgramID   common.PublicKey
 }
 
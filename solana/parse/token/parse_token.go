@@ -30,6 +30,7 @@ const (
 	InstructionMintToChecked
 	InstructionBurnChecked
 	InstructionInitializeAccount2
+	InstructionSyncNative
 )
 
 func ParseToken(ins types.Instruction) (stypes.ParsedInstruction, error) {
@@ -117,29 +118,41 @@ func ParseToken(ins types.Instruction) (stypes.ParsedInstruction, error) {
 	//	break
 	////case InstructionSetAuthority:
 	////	break
-	//case InstructionMintTo:
-	//	var a MintToInstruction
-	//	err = binstruct.UnmarshalLE(ins.Data, &a)
-	//	instructionType = "mintTo"
-	//	parsedInfo = map[string]interface{}{
-	//		"mint":    ins.Accounts[0].PubKey.ToBase58(),
-	//		"account": ins.Accounts[1].PubKey.ToBase58(),
-	//		"amount":  a.Amount,
-	//	}
-	//	parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "mintAuthority", "multisigMintAuthority")
-	//
-	//	break
-	//case InstructionCloseAccount:
-	//	var a CloseAccountInstruction
-	//	err = binstruct.UnmarshalLE(ins.Data, &a)
-	//	instructionType = "closeAccount"
-	//	parsedInfo = map[string]interface{}{
-	//		"account":     ins.Accounts[0].PubKey.ToBase58(),
-	//		"destination": ins.Accounts[1].PubKey.ToBase58(),
-	//	}
-	//	parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "owner", "multisigOwner")
-	//
-	//	break
+	case InstructionMintTo:
+		var a MintToInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "mintTo"
+		parsedInfo = map[string]interface{}{
+			"mint":    ins.Accounts[0].PubKey.ToBase58(),
+			"account": ins.Accounts[1].PubKey.ToBase58(),
+			"amount":  a.Amount,
+		}
+		parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "mintAuthority", "multisigMintAuthority")
+
+		break
+	case InstructionBurn:
+		var a BurnInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "burn"
+		parsedInfo = map[string]interface{}{
+			"account": ins.Accounts[0].PubKey.ToBase58(),
+			"mint":    ins.Accounts[1].PubKey.ToBase58(),
+			"amount":  a.Amount,
+		}
+		parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "authority", "multisigAuthority")
+
+		break
+	case InstructionCloseAccount:
+		var a CloseAccountInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "closeAccount"
+		parsedInfo = map[string]interface{}{
+			"account":     ins.Accounts[0].PubKey.ToBase58(),
+			"destination": ins.Accounts[1].PubKey.ToBase58(),
+		}
+		parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "owner", "multisigOwner")
+
+		break
 	//case InstructionFreezeAccount:
 	//	var a FreezeAccountInstruction
 	//	err = binstruct.UnmarshalLE(ins.Data, &a)
@@ -191,30 +204,36 @@ func ParseToken(ins types.Instruction) (stypes.ParsedInstruction, error) {
 	//	parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "owner", "owner")
 	//
 	//	break
-	//case InstructionMintToChecked:
-	//	var a MintToCheckedInstruction
-	//	err = binstruct.UnmarshalLE(ins.Data, &a)
-	//	instructionType = "mintToChecked"
-	//	parsedInfo = map[string]interface{}{
-	//		"account":     ins.Accounts[0].PubKey.ToBase58(),
-	//		"mint":        ins.Accounts[1].PubKey.ToBase58(),
-	//		"tokenAmount": tokenAmountToUiAmount(a.Amount, a.Decimals),
-	//	}
-	//	parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "authority", "multisigAuthority")
-	//
-	//	break
-	//case InstructionBurnChecked:
-	//	var a BurnCheckedInstruction
-	//	err = binstruct.UnmarshalLE(ins.Data, &a)
-	//	instructionType = "burnChecked"
-	//	parsedInfo = map[string]interface{}{
-	//		"account":     ins.Accounts[0].PubKey.ToBase58(),
-	//		"mint":        ins.Accounts[1].PubKey.ToBase58(),
-	//		"tokenAmount": tokenAmountToUiAmount(a.Amount, a.Decimals),
-	//	}
-	//	parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "authority", "multisigAuthority")
-	//
-	//	break
+	case InstructionMintToChecked:
+		var a MintToCheckedInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "mintToChecked"
+		parsedInfo = map[string]interface{}{
+			"mint":        ins.Accounts[0].PubKey.ToBase58(),
+			"account":     ins.Accounts[1].PubKey.ToBase58(),
+			"tokenAmount": tokenAmountToUiAmount(a.Amount, a.Decimals),
+		}
+		parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "mintAuthority", "multisigMintAuthority")
+
+		break
+	case InstructionBurnChecked:
+		var a BurnCheckedInstruction
+		err = binstruct.UnmarshalLE(ins.Data, &a)
+		instructionType = "burnChecked"
+		parsedInfo = map[string]interface{}{
+			"account":     ins.Accounts[0].PubKey.ToBase58(),
+			"mint":        ins.Accounts[1].PubKey.ToBase58(),
+			"tokenAmount": tokenAmountToUiAmount(a.Amount, a.Decimals),
+		}
+		parsedInfo = parse_signers(parsedInfo, 2, ins.Accounts, "authority", "multisigAuthority")
+
+		break
+	case InstructionSyncNative:
+		instructionType = "syncNative"
+		parsedInfo = map[string]interface{}{
+			"account": ins.Accounts[0].PubKey.ToBase58(),
+		}
+		break
 	default:
 		log.Printf("ERROR unknown type='%v'", s.Instruction)
 	}
@@ -0,0 +1,65 @@
+package solanago
+
+import (
+	"context"
+	"log"
+	"time"
+
+	ss "github.com/blocto/solana-go-sdk/client"
+)
+
+// Rebroadcaster re-sends the transactions in the mempool of a Client
+// until they land or can no longer land, as leaders may drop them.
+type Rebroadcaster struct {
+	client          *Client
+	interval        time.Duration
+	refreshInterval time.Duration
+}
+
+// NewRebroadcaster creates a Rebroadcaster that re-sends every interval
+// and drops landed or expired transactions every refreshInterval. An
+// interval of 0 only drops them.
+func NewRebroadcaster(client *Client, interval time.Duration, refreshInterval time.Duration) *Rebroadcaster {
+	return &Rebroadcaster{
+		client:          client,
+		interval:        interval,
+		refreshInterval: refreshInterval,
+	}
+}
+
+// Start rebroadcasts until ctx is done.
+func (r *Rebroadcaster) Start(ctx context.Context) error {
+	var resend <-chan time.Time
+	if r.interval > 0 {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		resend = ticker.C
+	}
+	refresh := time.NewTicker(r.refreshInterval)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-refresh.C:
+			if err := r.client.RefreshMempool(ctx); err != nil {
+				log.Printf("unable to refresh mempool: %s", err)
+			}
+		case <-resend:
+			r.rebroadcast(ctx)
+		}
+	}
+}
+
+// rebroadcast re-sends every transaction in the mempool. Preflight is
+// skipped as the transactions were simulated on their first submission.
+func (r *Rebroadcaster) rebroadcast(ctx context.Context) {
+	for _, tx := range r.client.Mempool.Transactions() {
+		_, err := r.client.Rpc.SendTransactionWithConfig(ctx, tx.Transaction, ss.SendTransactionConfig{
+			SkipPreflight: true,
+		})
+		if err != nil {
+			log.Printf("unable to rebroadcast %s: %s", tx.Hash, err)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package shared_types
 
 import (
+	"encoding/json"
+
 	solanago "github.com/blocto/solana-go-sdk/types"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	bin "github.com/streamingfast/binary"
@@ -71,6 +73,12 @@ const (
 	FeeCalculationKey  = "fee_calculation"
 	SplSystemAccMapKey = "spl_system_acc_map"
 	SplTokenAccMapKey  = "spl_token_acc_map"
+	NonceOptionsKey    = "nonce_options"
+	StakeRentExemptKey = "stake_rent_exempt"
+	FeePayerKey        = "fee_payer"
+
+	// NativeMint is the mint of wrapped SOL.
+	NativeMint = "So11111111111111111111111111111111111111112"
 
 	MainnetGenesisHash = "5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d"
 	TestnetGenesisHash = "4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY"
@@ -89,6 +97,11 @@ const (
 	System__AuthorizeNonce             = "System__AuthorizeNonce"
 	System__InitializeNonce            = "System__InitializeNonce"
 	System__Allocate                   = "System__Allocate"
+	System__CloseNonceAccount          = "System__CloseNonceAccount"
+	System__CreateAccountWithSeed      = "System__CreateAccountWithSeed"
+	System__AllocateWithSeed           = "System__AllocateWithSeed"
+	System__AssignWithSeed             = "System__AssignWithSeed"
+	System__TransferWithSeed           = "System__TransferWithSeed"
 	SplToken__Transfer                 = "SplToken__Transfer"
 	SplToken__InitializeMint           = "SplToken__InitializeMint"
 	SplToken__InitializeAccount        = "SplToken__InitializeAccount"
@@ -96,13 +109,15 @@ const (
 	SplToken__CreateAccount            = "SplToken__CreateAccount"
 	SplToken__Approve                  = "SplToken__Approve"
 	SplToken__Revoke                   = "SplToken__Revoke"
-	SplToken_MintTo                    = "SplToken_MintTo"
-	SplToken_Burn                      = "SplToken_Burn"
+	SplToken__MintTo                   = "SplToken__MintTo"
+	SplToken__Burn                     = "SplToken__Burn"
 	SplToken_CloseAccount              = "SplToken_CloseAccount"
 	SplToken_FreezeAccount             = "SplToken_FreezeAccount"
 	SplToken__TransferChecked          = "SplToken__TransferChecked"
 	SplToken__TransferNew              = "SplToken__TransferNew"
 	SplToken__TransferWithSystem       = "SplToken__TransferWithSystem"
+	SplToken__WrapSol                  = "SplToken__WrapSol"
+	SplToken__UnwrapSol                = "SplToken__UnwrapSol"
 	SplAssociatedTokenAccount__Create  = "SplAssociatedTokenAccount__Create"
 	Unknown                            = "Unknown"
 	Stake__CreateStakeAccount          = "Stake__CreateStakeAccount"
@@ -112,8 +127,17 @@ const (
 	Stake__WithdrawStake               = "Stake__WithdrawStake"
 	Stake__Merge                       = "Stake__Merge"
 	Stake__Split                       = "Stake__Split"
+	Stake__SplitWithSeed               = "Stake__SplitWithSeed"
 	Stake__Authorize                   = "Stake__Authorize"
+	Stake__AuthorizeChecked            = "Stake__AuthorizeChecked"
+	Stake__AuthorizeWithSeed           = "Stake__AuthorizeWithSeed"
+	Stake__SetLockup                   = "Stake__SetLockup"
+	Stake__SetLockupChecked            = "Stake__SetLockupChecked"
+	Stake__InitializeChecked           = "Stake__InitializeChecked"
+	Stake__Redelegate                  = "Stake__Redelegate"
 	ComputeBudget__SetComputeUnitPrice = "ComputeBudget__SetComputeUnitPrice"
+	Memo__Memo                         = "Memo__Memo"
+	Generic__Instruction               = "Generic__Instruction"
 )
 
 var (
@@ -155,6 +179,11 @@ var (
 		System__AuthorizeNonce,
 		System__InitializeNonce,
 		System__Allocate,
+		System__CloseNonceAccount,
+		System__CreateAccountWithSeed,
+		System__AllocateWithSeed,
+		System__AssignWithSeed,
+		System__TransferWithSeed,
 		SplToken__Transfer,
 		SplToken__InitializeMint,
 		SplToken__InitializeAccount,
@@ -162,13 +191,15 @@ var (
 		SplToken__CreateAccount,
 		SplToken__Approve,
 		SplToken__Revoke,
-		SplToken_MintTo,
-		SplToken_Burn,
+		SplToken__MintTo,
+		SplToken__Burn,
 		SplToken_CloseAccount,
 		SplToken_FreezeAccount,
 		SplToken__TransferChecked,
 		SplToken__TransferNew,
 		SplToken__TransferWithSystem,
+		SplToken__WrapSol,
+		SplToken__UnwrapSol,
 		SplAssociatedTokenAccount__Create,
 		Stake__CreateStakeAccount,
 		Stake__DelegateStake,
@@ -177,8 +208,17 @@ var (
 		Stake__WithdrawStake,
 		Stake__Merge,
 		Stake__Split,
+		Stake__SplitWithSeed,
 		Stake__Authorize,
+		Stake__AuthorizeChecked,
+		Stake__AuthorizeWithSeed,
+		Stake__SetLockup,
+		Stake__SetLockupChecked,
+		Stake__InitializeChecked,
+		Stake__Redelegate,
 		ComputeBudget__SetComputeUnitPrice,
+		Memo__Memo,
+		Generic__Instruction,
 		Unknown,
 	}
 
@@ -214,6 +254,11 @@ type ParsedInstructionMeta struct {
 	Amount       uint64            `json:"amount,omitempty"`
 	Lamports     uint64            `json:"lamports,omitempty"`
 	Space        uint64            `json:"space,omitempty"`
+
+	StakeAccount      string `json:"stakeAccount,omitempty"`
+	NewSplitAccount   string `json:"newSplitAccount,omitempty"`
+	StakeAuthority    string `json:"stakeAuthority,omitempty"`
+	WithdrawAuthority string `json:"withdrawAuthority,omitempty"`
 }
 type OpMetaTokenAmount struct {
 	Amount   string  `json:"amount,omitempty"`
@@ -235,10 +280,42 @@ type WithNonce struct {
 	Authority string `json:"authority,omitempty"`
 }
 
+// NonceOptions is passed from /construction/preprocess to
+// /construction/metadata so that every nonce account touched by the
+// operations can be fetched and validated.
+type NonceOptions struct {
+	// Accounts maps each nonce account to the authority given in the
+	// operation metadata, or "" when the authority was not specified.
+	Accounts   map[string]string `json:"accounts,omitempty"`
+	RentExempt bool              `json:"rent_exempt,omitempty"`
+}
+
+// NonceAccount is the on-chain state of an initialized nonce account.
+type NonceAccount struct {
+	Authority            string `json:"authority"`
+	BlockHash            string `json:"blockhash"`
+	LamportsPerSignature uint64 `json:"lamports_per_signature"`
+	Lamports             uint64 `json:"lamports"`
+}
+
+// NonceMetadata is the nonce state resolved by /construction/metadata.
+type NonceMetadata struct {
+	Accounts           map[string]NonceAccount `json:"accounts,omitempty"`
+	RentExemptLamports uint64                  `json:"rent_exempt_lamports,omitempty"`
+}
+
 type PriorityFee struct {
 	MicroLamports string `json:"microLamports"`
 }
 
+// SubmitOptions is the metadata accepted by /construction/submit.
+type SubmitOptions struct {
+	SkipPreflight       bool   `json:"skip_preflight"`
+	PreflightCommitment string `json:"preflight_commitment,omitempty"`
+	MaxRetries          uint64 `json:"max_retries,omitempty"`
+	WaitFor             string `json:"wait_for,omitempty"`
+}
+
 type FeeCalculation struct {
 	NumberOfInstructions string `json:"numberOfInstructions"`
 	NumberOfSigners      string `json:"number"`
@@ -271,8 +348,17 @@ type TransactionMeta struct {
 		Index        uint64        `json:"index"`
 		Instructions []Instruction `json:"instructions"`
 	} `json:"innerInstructions"`
-	Err    interface{}            `json:"err"`
-	Status map[string]interface{} `json:"status"`
+	Err               interface{}            `json:"err"`
+	Status            map[string]interface{} `json:"status"`
+	PreTokenBalances  []TokenBalance         `json:"preTokenBalances"`
+	PostTokenBalances []TokenBalance         `json:"postTokenBalances"`
+}
+
+type TokenBalance struct {
+	AccountIndex  int         `json:"accountIndex"`
+	Mint          string      `json:"mint"`
+	Owner         string      `json:"owner"`
+	UiTokenAmount TokenAmount `json:"uiTokenAmount"`
 }
 
 type InstructionInfo struct {
@@ -280,16 +366,80 @@ type InstructionInfo struct {
 	InstructionType string                 `json:"type"`
 }
 
+// UnmarshalJSON accepts the plain string the node returns as the parsed
+// form of spl-memo instructions.
+func (i *InstructionInfo) UnmarshalJSON(b []byte) error {
+	var memo string
+	if err := json.Unmarshal(b, &memo); err == nil {
+		i.Info = map[string]interface{}{"memo": memo}
+		i.InstructionType = "memo"
+		return nil
+	}
+	type instructionInfo InstructionInfo
+	return json.Unmarshal(b, (*instructionInfo)(i))
+}
+
 type Info struct {
 	Authority     string              `json:"authority"`
 	BlockHash     string              `json:"blockhash"`
 	FeeCalculator FeeCalculatorString `json:"feeCalculator"`
+	Meta          StakeMeta           `json:"meta"`
+	Stake         StakeState          `json:"stake"`
+	Owner         string              `json:"owner"`
+	Mint          string              `json:"mint"`
+	TokenAmount   TokenAmount         `json:"tokenAmount"`
+	Decimals      int32               `json:"decimals"`
+}
+
+// StakeMeta and StakeState are the jsonParsed info of a stake account.
+type StakeMeta struct {
+	Authorized        StakeAuthorized `json:"authorized"`
+	Lockup            StakeLockup     `json:"lockup"`
+	RentExemptReserve string          `json:"rentExemptReserve"`
+}
+type StakeAuthorized struct {
+	Staker     string `json:"staker"`
+	Withdrawer string `json:"withdrawer"`
+}
+type StakeLockup struct {
+	Custodian     string `json:"custodian"`
+	Epoch         uint64 `json:"epoch"`
+	UnixTimestamp int64  `json:"unixTimestamp"`
+}
+type StakeState struct {
+	Delegation StakeDelegation `json:"delegation"`
+}
+type StakeDelegation struct {
+	Voter             string `json:"voter"`
+	Stake             string `json:"stake"`
+	ActivationEpoch   string `json:"activationEpoch"`
+	DeactivationEpoch string `json:"deactivationEpoch"`
+}
+
+// StakeActivation is the state of the delegated lamports of a stake
+// account in the current epoch.
+type StakeActivation struct {
+	State    string `json:"state"`
+	Active   uint64 `json:"active"`
+	Inactive uint64 `json:"inactive"`
 }
 type Parsed struct {
-	Info Info `json:"info"`
+	Info Info   `json:"info"`
+	Type string `json:"type"`
 }
 type AccData struct {
-	Parsed Parsed `json:"parsed"`
+	Parsed  Parsed `json:"parsed"`
+	Program string `json:"program"`
+}
+
+// UnmarshalJSON leaves AccData empty when the node returns the raw
+// [data, encoding] pair because no jsonParsed parser exists for the account.
+func (d *AccData) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '[' {
+		return nil
+	}
+	type accData AccData
+	return json.Unmarshal(b, (*accData)(d))
 }
 
 type FeeCalculator struct {
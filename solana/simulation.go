@@ -0,0 +1,137 @@
+package solanago
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	solPTypes "github.com/blocto/solana-go-sdk/types"
+)
+
+// Causes of a failed simulation, matched with errors.Is on a
+// SimulationError.
+var (
+	ErrInsufficientFunds  = errors.New("insufficient funds")
+	ErrBlockhashNotFound  = errors.New("blockhash not found")
+	ErrAccountInUse       = errors.New("account in use")
+	ErrInstructionFailure = errors.New("instruction failed")
+)
+
+// systemInsufficientFunds and tokenInsufficientFunds are the custom
+// errors of the system and token programs for a balance too low.
+const (
+	systemInsufficientFunds = 1 // SystemError::ResultWithNegativeLamports
+	tokenInsufficientFunds  = 1 // TokenError::InsufficientFunds
+)
+
+// SimulationError is a transaction rejected by the preflight simulation
+// of sendTransaction.
+type SimulationError struct {
+	Message string
+	// Err is the TransactionError returned by the node.
+	Err  interface{}
+	Logs []string
+	// InstructionIndex and CustomCode are set for instruction errors,
+	// CustomCode only for program specific ones.
+	InstructionIndex *int
+	CustomCode       *uint32
+
+	cause error
+}
+
+func (e *SimulationError) Error() string {
+	return e.Message
+}
+
+func (e *SimulationError) Unwrap() error {
+	return e.cause
+}
+
+// ParseSimulationError returns the simulation failure of tx in err,
+// the error of sendTransaction, or nil if it is not one.
+func ParseSimulationError(err error, tx solPTypes.Transaction) *SimulationError {
+	var rpcErr *rpc.JsonRpcError
+	if !errors.As(err, &rpcErr) || rpcErr.Data == nil {
+		return nil
+	}
+	var data struct {
+		Err  interface{} `json:"err"`
+		Logs []string    `json:"logs"`
+	}
+	j, _ := json.Marshal(rpcErr.Data)
+	if json.Unmarshal(j, &data) != nil || data.Err == nil {
+		return nil
+	}
+
+	simErr := &SimulationError{
+		Message: rpcErr.Message,
+		Err:     data.Err,
+		Logs:    data.Logs,
+	}
+	switch e := data.Err.(type) {
+	case string:
+		simErr.cause = transactionErrorCause(e)
+	case map[string]interface{}:
+		if _, ok := e["InsufficientFundsForRent"]; ok {
+			simErr.cause = ErrInsufficientFunds
+		}
+		if ins, ok := e["InstructionError"].([]interface{}); ok && len(ins) == 2 {
+			simErr.parseInstructionError(ins, tx)
+		}
+	}
+	return simErr
+}
+
+func transactionErrorCause(e string) error {
+	switch e {
+	case "InsufficientFundsForFee", "InsufficientFundsForRent":
+		return ErrInsufficientFunds
+	case "BlockhashNotFound":
+		return ErrBlockhashNotFound
+	case "AccountInUse":
+		return ErrAccountInUse
+	}
+	return nil
+}
+
+// parseInstructionError reads an InstructionError, [index, error], where
+// error is a name or {"Custom": code}.
+func (e *SimulationError) parseInstructionError(ins []interface{}, tx solPTypes.Transaction) {
+	e.cause = ErrInstructionFailure
+	index, ok := ins[0].(float64)
+	if !ok {
+		return
+	}
+	i := int(index)
+	e.InstructionIndex = &i
+
+	switch insErr := ins[1].(type) {
+	case string:
+		if insErr == "InsufficientFunds" {
+			e.cause = ErrInsufficientFunds
+		}
+	case map[string]interface{}:
+		code, ok := insErr["Custom"].(float64)
+		if !ok {
+			return
+		}
+		c := uint32(code)
+		e.CustomCode = &c
+
+		instructions := tx.Message.DecompileInstructions()
+		if i >= len(instructions) {
+			return
+		}
+		switch instructions[i].ProgramID {
+		case common.SystemProgramID:
+			if c == systemInsufficientFunds {
+				e.cause = ErrInsufficientFunds
+			}
+		case common.TokenProgramID, common.Token2022ProgramID:
+			if c == tokenInsufficientFunds {
+				e.cause = ErrInsufficientFunds
+			}
+		}
+	}
+}
@@ -20,20 +20,198 @@ import (
 func IsBalanceChanging(opType string) bool {
 	a := false
 	switch opType {
-	case stypes.System__CreateAccount, stypes.System__WithdrawFromNonce, stypes.System__Transfer, stypes.SplToken__Transfer, stypes.SplToken__TransferChecked, "Stake__Split", "Stake__Withdraw", "Vote__Withdraw", stypes.SplToken__TransferNew, stypes.SplToken__TransferWithSystem:
+	case stypes.System__CreateAccount, stypes.System__CreateAccountWithSeed, stypes.System__WithdrawFromNonce, stypes.System__Transfer, stypes.System__TransferWithSeed, stypes.SplToken__Transfer, stypes.SplToken__TransferChecked, stypes.Stake__Split, stypes.Stake__WithdrawStake, "Vote__Withdraw", stypes.SplToken__TransferNew, stypes.SplToken__TransferWithSystem:
 		a = true
 	}
 	return a
 }
 
+// programOperationPrefixes maps program names whose operation types do
+// not follow the program name.
+var programOperationPrefixes = map[string]string{
+	"spl-memo": "Memo",
+}
+
+// operationTypeAliases maps parsed instructions to the operation type
+// constructing them.
+var operationTypeAliases = map[string]string{
+	"Stake__Withdraw":   stypes.Stake__WithdrawStake,
+	"Stake__Delegate":   stypes.Stake__DelegateStake,
+	"Stake__Deactivate": stypes.Stake__DeactivateStake,
+
+	"SplToken__MintToChecked": stypes.SplToken__MintTo,
+	"SplToken__BurnChecked":   stypes.SplToken__Burn,
+}
+
+// TokenAccountIdentifier keys a token account by its owner, with the token
+// account as sub-account.
+func TokenAccountIdentifier(owner string, tokenAccount string) *types.AccountIdentifier {
+	return &types.AccountIdentifier{
+		Address:    owner,
+		SubAccount: &types.SubAccountIdentifier{Address: tokenAccount},
+		Metadata:   map[string]interface{}{},
+	}
+}
+
+// StakeAccountIdentifier keys a stake account by its withdraw authority,
+// with the stake account as sub-account.
+func StakeAccountIdentifier(withdrawer string, stakeAccount string) *types.AccountIdentifier {
+	return &types.AccountIdentifier{
+		Address:    withdrawer,
+		SubAccount: &types.SubAccountIdentifier{Address: stakeAccount},
+		Metadata:   map[string]interface{}{},
+	}
+}
+
+// SubAccountAddress returns the account an identifier refers to on chain,
+// the sub-account if there is one.
+func SubAccountAddress(account *types.AccountIdentifier) string {
+	if account == nil {
+		return ""
+	}
+	if account.SubAccount != nil && account.SubAccount.Address != "" {
+		return account.SubAccount.Address
+	}
+	return account.Address
+}
+
+// TokenAccountOwners maps the token accounts in the token balances of a
+// transaction to their owners.
+func TokenAccountOwners(tx stypes.ParsedTransaction, meta stypes.TransactionMeta) map[string]string {
+	owners := make(map[string]string)
+	for _, balances := range [][]stypes.TokenBalance{meta.PreTokenBalances, meta.PostTokenBalances} {
+		for _, balance := range balances {
+			if balance.Owner != "" && balance.AccountIndex < len(tx.Message.AccountKeys) {
+				owners[tx.Message.AccountKeys[balance.AccountIndex].PubKey] = balance.Owner
+			}
+		}
+	}
+	return owners
+}
+
+// stakeInstruction holds the fields of a parsed stake instruction that
+// name its stake accounts and their withdraw authority.
+type stakeInstruction struct {
+	StakeAccount      string `json:"stakeAccount"`
+	Source            string `json:"source"`
+	Destination       string `json:"destination"`
+	WithdrawAuthority string `json:"withdrawAuthority"`
+	Withdrawer        string `json:"withdrawer"`
+	Authority         string `json:"authority"`
+	AuthorityType     string `json:"authorityType"`
+	Authorized        struct {
+		Withdrawer string `json:"withdrawer"`
+	} `json:"authorized"`
+}
+
+// stakeInstructions returns the parsed stake instructions of tx.
+func stakeInstructions(tx stypes.ParsedTransaction) []stakeInstruction {
+	var stakeIns []stakeInstruction
+	for _, ins := range tx.Message.Instructions {
+		if ins.Program != "stake" || ins.Parsed == nil {
+			continue
+		}
+		var info stakeInstruction
+		j, _ := json.Marshal(ins.Parsed.Info)
+		json.Unmarshal(j, &info)
+		if ins.Parsed.InstructionType != "merge" {
+			// the destination of a withdraw is a system account
+			info.Source, info.Destination = "", ""
+		}
+		stakeIns = append(stakeIns, info)
+	}
+	return stakeIns
+}
+
+// StakeAccounts returns the stake accounts the stake instructions of tx
+// operate on, without the accounts they split off.
+func StakeAccounts(tx stypes.ParsedTransaction) []string {
+	var accounts []string
+	for _, info := range stakeInstructions(tx) {
+		for _, account := range []string{info.StakeAccount, info.Source, info.Destination} {
+			if account != "" && !Contains(accounts, account) {
+				accounts = append(accounts, account)
+			}
+		}
+	}
+	return accounts
+}
+
+// StakeAccountWithdrawers maps the stake accounts of a transaction to
+// their withdraw authority: the one its instructions name, i.e. the
+// authority of a withdraw or of a withdrawer authorization or the
+// withdrawer an account is initialized with, or else the one in current,
+// the withdrawers fetched from the state of the accounts. The source of
+// a merge has the authorities of its destination.
+func StakeAccountWithdrawers(tx stypes.ParsedTransaction, current map[string]string) map[string]string {
+	withdrawers := make(map[string]string)
+	stakeIns := stakeInstructions(tx)
+	for _, info := range stakeIns {
+		withdrawer := info.WithdrawAuthority
+		if withdrawer == "" {
+			withdrawer = info.Withdrawer
+		}
+		if withdrawer == "" {
+			withdrawer = info.Authorized.Withdrawer
+		}
+		if withdrawer == "" && info.AuthorityType == "Withdrawer" {
+			withdrawer = info.Authority
+		}
+		if _, ok := withdrawers[info.StakeAccount]; !ok && info.StakeAccount != "" && withdrawer != "" {
+			withdrawers[info.StakeAccount] = withdrawer
+		}
+	}
+	for _, account := range StakeAccounts(tx) {
+		if _, ok := withdrawers[account]; !ok && current[account] != "" {
+			withdrawers[account] = current[account]
+		}
+	}
+	for _, info := range stakeIns {
+		if _, ok := withdrawers[info.Source]; !ok && info.Source != "" && withdrawers[info.Destination] != "" {
+			withdrawers[info.Source] = withdrawers[info.Destination]
+		}
+	}
+	return withdrawers
+}
+
+// stakeAccountIdentifier keys stakeAccount by the withdraw authority of
+// source, or by itself when it is unknown, e.g. for a transaction that
+// is not on chain yet; /account/balance accepts both.
+func stakeAccountIdentifier(withdrawers map[string]string, source string, stakeAccount string) *types.AccountIdentifier {
+	if withdrawer, ok := withdrawers[source]; ok {
+		return StakeAccountIdentifier(withdrawer, stakeAccount)
+	}
+	return plainAccountIdentifier(stakeAccount)
+}
+
+func plainAccountIdentifier(address string) *types.AccountIdentifier {
+	return &types.AccountIdentifier{
+		Address:  address,
+		Metadata: map[string]interface{}{},
+	}
+}
+
+func tokenAccountIdentifier(owners map[string]string, tokenAccount string) *types.AccountIdentifier {
+	if owner, ok := owners[tokenAccount]; ok {
+		return TokenAccountIdentifier(owner, tokenAccount)
+	}
+	return plainAccountIdentifier(tokenAccount)
+}
+
 func getOperationTypeWithProgram(program string, s string) string {
 	toPascal := strcase.ToCamel(program)
+	if prefix, ok := programOperationPrefixes[program]; ok {
+		toPascal = prefix
+	}
 
 	newStr := fmt.Sprint(
 		toPascal,
 		stypes.Separator,
 		strcase.ToCamel(s),
 	)
+	if alias, ok := operationTypeAliases[newStr]; ok {
+		return alias
+	}
 	return newStr
 }
 func getOperationType(s string) string {
@@ -47,6 +225,15 @@ func split_at(at int, input []byte) ([]byte, []byte) {
 	return input[0:1], input[1:]
 }
 func GetRosOperationsFromTx(tx stypes.ParsedTransaction, status string) []*types.Operation {
+	return GetRosOperationsFromTxWithMeta(tx, stypes.TransactionMeta{}, nil, status)
+}
+
+// GetRosOperationsFromTxWithMeta also keys token accounts by their owners
+// when the token balances of meta name them, and stake accounts by the
+// withdrawers that tx names or that are given in stakeWithdrawers.
+func GetRosOperationsFromTxWithMeta(tx stypes.ParsedTransaction, meta stypes.TransactionMeta, stakeWithdrawers map[string]string, status string) []*types.Operation {
+	tokenOwners := TokenAccountOwners(tx, meta)
+	stakeWithdrawers = StakeAccountWithdrawers(tx, stakeWithdrawers)
 	//	hash := tx.Transaction.Signatures[0].String()
 	opIndex := int64(0)
 	var operations []*types.Operation
@@ -116,26 +303,40 @@ func GetRosOperationsFromTx(tx stypes.ParsedTransaction, status string) []*types
 				}
 
 				source := parsedInstructionMeta.Source
+				if source == "" {
+					source = parsedInstructionMeta.StakeAccount
+				}
 				if source == "" {
 					source = parsedInstructionMeta.Owner
 				}
-				sender := types.AccountIdentifier{
-					Address:  source,
-					Metadata: map[string]interface{}{},
+				destination := parsedInstructionMeta.Destination
+				if destination == "" {
+					destination = parsedInstructionMeta.NewAccount
+				}
+				if destination == "" {
+					destination = parsedInstructionMeta.NewSplitAccount
+				}
+				var sender, receiver *types.AccountIdentifier
+				switch ins.Program {
+				case "spl-token":
+					sender = tokenAccountIdentifier(tokenOwners, source)
+					receiver = tokenAccountIdentifier(tokenOwners, destination)
+				case "stake":
+					// the split account keeps the authorities of the source
+					sender = stakeAccountIdentifier(stakeWithdrawers, source, source)
+					receiver = plainAccountIdentifier(destination)
+					if parsedInstructionMeta.NewSplitAccount != "" {
+						receiver = stakeAccountIdentifier(stakeWithdrawers, source, destination)
+					}
+				default:
+					sender = plainAccountIdentifier(source)
+					receiver = plainAccountIdentifier(destination)
 				}
 				senderAmt := types.Amount{
 					Value:    "-" + fmt.Sprint(parsedInstructionMeta.Amount),
 					Currency: &currency,
 				}
 
-				destination := parsedInstructionMeta.Destination
-				if destination == "" {
-					destination = parsedInstructionMeta.NewAccount
-				}
-				receiver := types.AccountIdentifier{
-					Address:  destination,
-					Metadata: map[string]interface{}{},
-				}
 				receiverAmt := types.Amount{
 					Value:    fmt.Sprint(parsedInstructionMeta.Amount),
 					Currency: &currency,
@@ -150,26 +351,33 @@ func GetRosOperationsFromTx(tx stypes.ParsedTransaction, status string) []*types
 				delete(inInterface, "lamports")
 				delete(inInterface, "source")
 				delete(inInterface, "destination")
+				delete(inInterface, "stakeAccount")
+				delete(inInterface, "newSplitAccount")
 
 				//sender push
 				operations = append(operations, &types.Operation{
 					OperationIdentifier: &oi,
 					Type:                opType,
 					Status:              &status,
-					Account:             &sender,
+					Account:             sender,
 					Amount:              &senderAmt,
 					Metadata:            inInterface,
 				}, &types.Operation{
 					OperationIdentifier: &oi2,
 					Type:                opType,
 					Status:              &status,
-					Account:             &receiver,
+					Account:             receiver,
 					Amount:              &receiverAmt,
 					Metadata:            inInterface,
 				})
 			} else {
 				var account types.AccountIdentifier
-				if parsedInstructionMeta.Source != "" {
+				if stakeAccount := parsedInstructionMeta.StakeAccount; ins.Program == "stake" && stakeAccount != "" {
+					account = *stakeAccountIdentifier(stakeWithdrawers, stakeAccount, stakeAccount)
+				} else if source := parsedInstructionMeta.Source; ins.Program == "stake" && source != "" {
+					// the source of a merge
+					account = *stakeAccountIdentifier(stakeWithdrawers, source, source)
+				} else if parsedInstructionMeta.Source != "" {
 					account = types.AccountIdentifier{
 						Address: parsedInstructionMeta.Source,
 					}
@@ -187,10 +395,20 @@ func GetRosOperationsFromTx(tx stypes.ParsedTransaction, status string) []*types
 					}
 				}
 
+				if account.Address == "" {
+					if signers, ok := inInterface["signers"].([]interface{}); ok && len(signers) > 0 {
+						account.Address, _ = signers[0].(string)
+					}
+				}
+				var accountIdentifier *types.AccountIdentifier
+				if account.Address != "" {
+					accountIdentifier = &account
+				}
+
 				operations = append(operations, &types.Operation{
 					OperationIdentifier: &oi,
 					Type:                opType,
-					Account:             &account,
+					Account:             accountIdentifier,
 					Status:              &status,
 					Metadata:            inInterface,
 				})
@@ -200,20 +418,20 @@ func GetRosOperationsFromTx(tx stypes.ParsedTransaction, status string) []*types
 	return operations
 }
 
-func ToRosTxs(txs []stypes.ParsedTransactionWithMeta) []*RosettaTypes.Transaction {
+func ToRosTxs(txs []stypes.ParsedTransactionWithMeta, stakeWithdrawers map[string]string) []*RosettaTypes.Transaction {
 	var rtxs []*RosettaTypes.Transaction
 	for _, tx := range txs {
-		rtx := ToRosTx(tx.Transaction)
+		rtx := ToRosTx(tx.Transaction, tx.Meta, stakeWithdrawers)
 		rtxs = append(rtxs, &rtx)
 	}
 	return rtxs
 }
-func ToRosTx(tx stypes.ParsedTransaction) RosettaTypes.Transaction {
+func ToRosTx(tx stypes.ParsedTransaction, meta stypes.TransactionMeta, stakeWithdrawers map[string]string) RosettaTypes.Transaction {
 	return RosettaTypes.Transaction{
 		TransactionIdentifier: &RosettaTypes.TransactionIdentifier{
 			Hash: tx.Signatures[0],
 		},
-		Operations: GetRosOperationsFromTx(tx, stypes.SuccessStatus),
+		Operations: GetRosOperationsFromTxWithMeta(tx, meta, stakeWithdrawers, stypes.SuccessStatus),
 		Metadata:   map[string]interface{}{},
 	}
 }
@@ -252,6 +470,15 @@ func GetWithNonce(m map[string]interface{}) (stypes.WithNonce, bool) {
 	return withNonce, hasNonce
 }
 
+func GetNonceOptions(m map[string]interface{}) stypes.NonceOptions {
+	var nonceOptions stypes.NonceOptions
+	if w, ok := m[stypes.NonceOptionsKey]; ok {
+		j, _ := json.Marshal(w)
+		json.Unmarshal(j, &nonceOptions)
+	}
+	return nonceOptions
+}
+
 func GetPriorityFee(m map[string]interface{}) stypes.PriorityFee {
 	var priorityFee stypes.PriorityFee
 	if w, ok := m[stypes.PriorityFeeKey]; ok {
@@ -261,6 +488,20 @@ func GetPriorityFee(m map[string]interface{}) stypes.PriorityFee {
 	return priorityFee
 }
 
+// GetFeePayer returns the fee payer of the transaction, empty if the
+// first signer pays.
+func GetFeePayer(m map[string]interface{}) string {
+	feePayer, _ := m[stypes.FeePayerKey].(string)
+	return feePayer
+}
+
+func GetSubmitOptions(m map[string]interface{}) (stypes.SubmitOptions, error) {
+	var submitOptions stypes.SubmitOptions
+	j, _ := json.Marshal(m)
+	err := json.Unmarshal(j, &submitOptions)
+	return submitOptions, err
+}
+
 func GetFeeCalculation(m map[string]interface{}) stypes.FeeCalculation {
 	var feeCalculation stypes.FeeCalculation
 	if w, ok := m[stypes.FeeCalculationKey]; ok {
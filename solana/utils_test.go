@@ -1,7 +1,11 @@
 package solanago
 
 import (
+	"encoding/json"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/memo"
 	"github.com/imerkle/rosetta-solana-go/solana/parse"
+	stypes "github.com/imerkle/rosetta-solana-go/solana/shared_types"
 	"testing"
 
 	"github.com/test-go/testify/assert"
@@ -18,3 +22,196 @@ func TestTxParse(t *testing.T) {
 	_, err = parse.ToParsedTransaction(tx)
 	assert.NoError(t, err)
 }
+
+func TestMemoOperation(t *testing.T) {
+	signer := common.PublicKeyFromString("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH")
+	ins := memo.BuildMemo(memo.BuildMemoParam{SignerPubkeys: []common.PublicKey{signer}, Memo: []byte("deposit 42")})
+	parsedIns, err := parse.ParseInstruction(ins)
+	assert.NoError(t, err)
+
+	ops := GetRosOperationsFromTx(stypes.ParsedTransaction{
+		Message: stypes.ParsedMessage{Instructions: []stypes.ParsedInstruction{parsedIns}},
+	}, "")
+	assert.Equal(t, 1, len(ops))
+	assert.Equal(t, stypes.Memo__Memo, ops[0].Type)
+	assert.Equal(t, signer.ToBase58(), ops[0].Account.Address)
+	assert.Equal(t, "deposit 42", ops[0].Metadata["memo"])
+
+	var rpcIns stypes.ParsedInstruction
+	err = json.Unmarshal([]byte(`{"parsed":"deposit 42","program":"spl-memo","programId":"MemoSq4gqABAXKb96qnH8TysNcWxMyWCqXgDLGmfcHr"}`), &rpcIns)
+	assert.NoError(t, err)
+	assert.Equal(t, "deposit 42", rpcIns.Parsed.Info["memo"])
+}
+
+func TestStakeActivation(t *testing.T) {
+	var acc GetAccountInfoParsedResponse
+	err := json.Unmarshal([]byte(`{
+		"lamports": 1002282880,
+		"owner": "Stake11111111111111111111111111111111111111",
+		"data": {
+			"program": "stake",
+			"parsed": {
+				"type": "delegated",
+				"info": {
+					"meta": {
+						"authorized": {
+							"staker": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH",
+							"withdrawer": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"
+						},
+						"lockup": {"custodian": "11111111111111111111111111111111", "epoch": 0, "unixTimestamp": 0},
+						"rentExemptReserve": "2282880"
+					},
+					"stake": {
+						"creditsObserved": 1,
+						"delegation": {
+							"voter": "9QU2QSxhb24FUX3Tu2FpczXjpK3VYrvRudywSZaM29mF",
+							"stake": "1000000000",
+							"activationEpoch": "100",
+							"deactivationEpoch": "18446744073709551615",
+							"warmupCooldownRate": 0.25
+						}
+					}
+				}
+			}
+		}
+	}`), &acc)
+	assert.NoError(t, err)
+	assert.Equal(t, "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", acc.Data.Parsed.Info.Meta.Authorized.Staker)
+
+	assert.Equal(t, stypes.StakeActivation{State: "activating", Inactive: 1000000000}, ToStakeActivation(acc, 100))
+	assert.Equal(t, stypes.StakeActivation{State: "active", Active: 1000000000}, ToStakeActivation(acc, 101))
+
+	acc.Data.Parsed.Info.Stake.Delegation.DeactivationEpoch = "105"
+	assert.Equal(t, stypes.StakeActivation{State: "deactivating", Active: 1000000000}, ToStakeActivation(acc, 105))
+	assert.Equal(t, stypes.StakeActivation{State: "inactive", Inactive: 1000000000}, ToStakeActivation(acc, 106))
+}
+
+func TestSubAccountOperations(t *testing.T) {
+	var tx stypes.ParsedTransactionWithMeta
+	err := json.Unmarshal([]byte(`{
+		"meta": {
+			"postTokenBalances": [
+				{"accountIndex": 1, "mint": "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr", "owner": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", "uiTokenAmount": {"amount": "9", "decimals": 2}},
+				{"accountIndex": 2, "mint": "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr", "owner": "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n", "uiTokenAmount": {"amount": "1", "decimals": 2}}
+			]
+		},
+		"transaction": {
+			"signatures": ["5Jx"],
+			"message": {
+				"accountKeys": [
+					{"pubkey": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"},
+					{"pubkey": "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV"},
+					{"pubkey": "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L"}
+				],
+				"instructions": [
+					{"program": "spl-token", "programId": "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA", "parsed": {"type": "transfer", "info": {
+						"source": "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV",
+						"destination": "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L",
+						"authority": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH",
+						"amount": "1"
+					}}},
+					{"program": "stake", "programId": "Stake11111111111111111111111111111111111111", "parsed": {"type": "withdraw", "info": {
+						"stakeAccount": "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm",
+						"destination": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH",
+						"withdrawAuthority": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH",
+						"lamports": 5000
+					}}}
+				]
+			}
+		}
+	}`), &tx)
+	assert.NoError(t, err)
+
+	rosTx := ToRosTx(tx.Transaction, tx.Meta, nil)
+	ops := rosTx.Operations
+	assert.Equal(t, 4, len(ops))
+	assert.Equal(t, TokenAccountIdentifier("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV"), ops[0].Account)
+	assert.Equal(t, TokenAccountIdentifier("CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n", "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L"), ops[1].Account)
+	assert.Equal(t, stypes.Stake__WithdrawStake, ops[2].Type)
+	assert.Equal(t, StakeAccountIdentifier("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"), ops[2].Account)
+	assert.Equal(t, "-5000", ops[2].Amount.Value)
+	assert.Equal(t, "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", ops[3].Account.Address)
+	assert.Nil(t, ops[3].Account.SubAccount)
+
+	withoutMeta := GetRosOperationsFromTx(tx.Transaction, "")
+	assert.Equal(t, "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV", withoutMeta[0].Account.Address)
+	assert.Equal(t, "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV", SubAccountAddress(ops[0].Account))
+}
+
+func TestStakeSubAccountOperations(t *testing.T) {
+	var tx stypes.ParsedTransactionWithMeta
+	err := json.Unmarshal([]byte(`{
+		"meta": {},
+		"transaction": {
+			"signatures": ["5Jx"],
+			"message": {
+				"accountKeys": [
+					{"pubkey": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"}
+				],
+				"instructions": [
+					{"program": "stake", "programId": "Stake11111111111111111111111111111111111111", "parsed": {"type": "initialize", "info": {
+						"stakeAccount": "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm",
+						"authorized": {"staker": "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n", "withdrawer": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH"}
+					}}},
+					{"program": "stake", "programId": "Stake11111111111111111111111111111111111111", "parsed": {"type": "split", "info": {
+						"stakeAccount": "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm",
+						"newSplitAccount": "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV",
+						"stakeAuthority": "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n",
+						"lamports": 5000
+					}}},
+					{"program": "stake", "programId": "Stake11111111111111111111111111111111111111", "parsed": {"type": "deactivate", "info": {
+						"stakeAccount": "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L",
+						"stakeAuthority": "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n"
+					}}},
+					{"program": "stake", "programId": "Stake11111111111111111111111111111111111111", "parsed": {"type": "merge", "info": {
+						"destination": "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L",
+						"source": "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr",
+						"stakeAuthority": "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n"
+					}}}
+				]
+			}
+		}
+	}`), &tx)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm", "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L", "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr"}, StakeAccounts(tx.Transaction))
+
+	// the withdrawer of an account the transaction does not name is fetched
+	current := map[string]string{"GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L": "CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n"}
+	ops := ToRosTx(tx.Transaction, tx.Meta, current).Operations
+	assert.Equal(t, 5, len(ops))
+	assert.Equal(t, StakeAccountIdentifier("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"), ops[0].Account)
+	assert.Equal(t, StakeAccountIdentifier("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", "CgbKUPxEiY2PEXPLqFmtYxUfoYjLBmKEKnZEA3qnxrhm"), ops[1].Account)
+	assert.Equal(t, "-5000", ops[1].Amount.Value)
+	assert.Equal(t, StakeAccountIdentifier("HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV"), ops[2].Account)
+	assert.Equal(t, "5000", ops[2].Amount.Value)
+	assert.Equal(t, StakeAccountIdentifier("CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n", "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L"), ops[3].Account)
+
+	// the closed source of a merge has the withdrawer of its destination
+	assert.Equal(t, StakeAccountIdentifier("CgVKbBwogjaqtGtPLkMBSkhwtkTMLVdSdHM5cWzyxT5n", "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr"), ops[4].Account)
+
+	// without the fetched withdrawer the account keeps its own address
+	ops = ToRosTx(tx.Transaction, tx.Meta, nil).Operations
+	assert.Equal(t, "GyUjMMeZH3PVXp4tk5sR8LgnVaLTvCPipQ3dQY74k75L", ops[3].Account.Address)
+	assert.Nil(t, ops[3].Account.SubAccount)
+}
+
+func TestCheckedTokenOperations(t *testing.T) {
+	var tx stypes.ParsedTransaction
+	err := json.Unmarshal([]byte(`{
+		"signatures": ["5Jx"],
+		"message": {
+			"instructions": [
+				{"program": "spl-token", "parsed": {"type": "mintToChecked", "info": {"mint": "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr", "account": "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV", "mintAuthority": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", "tokenAmount": {"amount": "5", "decimals": 2}}}},
+				{"program": "spl-token", "parsed": {"type": "burnChecked", "info": {"mint": "3fJRYbtSYZo9SYhwgUBn2zjG98ASy3kuUEnZeHJXqREr", "account": "95Dq3sXa3omVjiyxBSD6UMrzPYdmyu6CFCw5wS4rhqgV", "authority": "HJGPMwVuqrbm7BDMeA3shLkqdHUru337fgytM7HzqTnH", "tokenAmount": {"amount": "5", "decimals": 2}}}}
+			]
+		}
+	}`), &tx)
+	assert.NoError(t, err)
+
+	// the checked instructions are what SplToken__MintTo and SplToken__Burn build
+	ops := GetRosOperationsFromTx(tx, "")
+	assert.Equal(t, 2, len(ops))
+	assert.Equal(t, stypes.SplToken__MintTo, ops[0].Type)
+	assert.Equal(t, stypes.SplToken__Burn, ops[1].Type)
+}